@@ -0,0 +1,189 @@
+// Package bookcsv reads and writes books as CSV one row at a time, so files
+// of any size can be imported and exported without holding them in memory.
+package bookcsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"server/repositories"
+	"strconv"
+	"strings"
+)
+
+// Fields of a book, which are also the default headers of their columns.
+const (
+	FieldISBN        = "isbn"
+	FieldName        = "name"
+	FieldPublishYear = "publish_year"
+	FieldAuthors     = "authors"
+)
+
+var Fields = []string{FieldISBN, FieldName, FieldPublishYear, FieldAuthors}
+
+// required are the fields a file must have a column for. Books may come
+// without authors.
+var required = []string{FieldISBN, FieldName, FieldPublishYear}
+
+// AuthorSeparator separates the authors of a book within their cell.
+const AuthorSeparator = ";"
+
+var (
+	// ErrHeader is a header row the books cannot be read with.
+	ErrHeader = errors.New("CSV header is not valid")
+	// ErrRow is a row that cannot be read as a book. Reading can go on
+	// after it.
+	ErrRow = errors.New("CSV row is not valid")
+)
+
+// Mapping tells which field the column with a given header holds, for
+// files whose headers are not the field names.
+type Mapping map[string]string
+
+// Reader reads books from a CSV file whose first row is a header.
+type Reader struct {
+	csv     *csv.Reader
+	header  []string
+	columns map[string]int
+	line    int
+}
+
+// NewReader reads the header of the file and finds the column of every
+// field, through mapping first and then by name, ignoring case. Columns of
+// no field are ignored.
+func NewReader(r io.Reader, mapping Mapping) (*Reader, error) {
+	for header, field := range mapping {
+		if !isField(field) {
+			return nil, fmt.Errorf("%w: %q is mapped to %q, which is not one of %s", ErrHeader, header, field, strings.Join(Fields, ", "))
+		}
+	}
+	reader := &Reader{csv: csv.NewReader(r), columns: map[string]int{}}
+	reader.csv.FieldsPerRecord = -1
+	header, err := reader.csv.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file is empty", ErrHeader)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrHeader, err)
+	}
+	if len(header) > 0 {
+		// Spreadsheets like to start their exports with a byte order mark.
+		header[0] = strings.TrimPrefix(header[0], "\uFEFF")
+	}
+	reader.header = header
+
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		field, mapped := mapping[name]
+		if !mapped {
+			field = strings.ToLower(name)
+		}
+		if !isField(field) {
+			continue
+		}
+		if _, taken := reader.columns[field]; taken {
+			return nil, fmt.Errorf("%w: more than one column holds %s", ErrHeader, field)
+		}
+		reader.columns[field] = i
+	}
+	for _, field := range required {
+		if _, ok := reader.columns[field]; !ok {
+			return nil, fmt.Errorf("%w: no column holds %s", ErrHeader, field)
+		}
+	}
+	return reader, nil
+}
+
+func isField(name string) bool {
+	for _, field := range Fields {
+		if name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Header returns the header row as it was read.
+func (reader *Reader) Header() []string {
+	return reader.header
+}
+
+// Line returns the line the last row read starts on.
+func (reader *Reader) Line() int {
+	return reader.line
+}
+
+// Read returns the next row as it was read and the book it holds. A row
+// that cannot be read is returned with an error wrapping ErrRow, and its
+// record is nil when not even the CSV could be read. The end of the file
+// is io.EOF.
+func (reader *Reader) Read() ([]string, repositories.Book, error) {
+	book := repositories.Book{Authors: []string{}}
+	record, err := reader.csv.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		reader.line = parseErr.StartLine
+		return record, book, fmt.Errorf("%w: %s", ErrRow, parseErr.Err)
+	}
+	if err != nil {
+		return nil, book, err
+	}
+	reader.line, _ = reader.csv.FieldPos(0)
+
+	cell := func(field string) string {
+		i, ok := reader.columns[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	book.ISBN = cell(FieldISBN)
+	book.Name = cell(FieldName)
+	if year := cell(FieldPublishYear); year != "" {
+		book.PublishYear, err = strconv.Atoi(year)
+		if err != nil {
+			return record, book, fmt.Errorf("%w: %s %q is not a number", ErrRow, FieldPublishYear, year)
+		}
+	}
+	for _, author := range strings.Split(cell(FieldAuthors), AuthorSeparator) {
+		if author = strings.TrimSpace(author); author != "" {
+			book.Authors = append(book.Authors, author)
+		}
+	}
+	return record, book, nil
+}
+
+// Writer writes books as CSV under a header of the field names, in the
+// layout Reader reads without a mapping.
+type Writer struct {
+	csv    *csv.Writer
+	header bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{csv: csv.NewWriter(w)}
+}
+
+// Write writes book as one row, after the header if it is the first.
+func (writer *Writer) Write(book repositories.Book) error {
+	if !writer.header {
+		if err := writer.WriteHeader(); err != nil {
+			return err
+		}
+	}
+	return writer.csv.Write([]string{book.ISBN, book.Name, strconv.Itoa(book.PublishYear), strings.Join(book.Authors, AuthorSeparator)})
+}
+
+// WriteHeader writes the header row, which a file without books still
+// needs. Write calls it for the first book.
+func (writer *Writer) WriteHeader() error {
+	writer.header = true
+	return writer.csv.Write(Fields)
+}
+
+// Flush writes out the rows buffered so far.
+func (writer *Writer) Flush() error {
+	writer.csv.Flush()
+	return writer.csv.Error()
+}
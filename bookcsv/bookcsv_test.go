@@ -0,0 +1,102 @@
+package bookcsv_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"server/bookcsv"
+	"server/repositories"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	file := "\uFEFFISBN,Name,Publish_Year,Authors,Shelf\n" +
+		"9780306406157,Atomic,2022,Albert; Victor,B2\n" +
+		"\"0-306-40615-2\",\"Skinner, 2nd\",2001,,A1\n" +
+		"9780804429573,Short\n" +
+		"9780804429573,Year,soon,,C3\n"
+	reader, err := bookcsv.NewReader(strings.NewReader(file), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []repositories.Book{
+		{ISBN: "9780306406157", Name: "Atomic", PublishYear: 2022, Authors: []string{"Albert", "Victor"}},
+		{ISBN: "0-306-40615-2", Name: "Skinner, 2nd", PublishYear: 2001, Authors: []string{}},
+		{ISBN: "9780804429573", Name: "Short", Authors: []string{}},
+	}
+	for i, book := range expected {
+		record, got, err := reader.Read()
+		if err != nil || !reflect.DeepEqual(got, book) {
+			t.Errorf("Row %d: expected %v, got %v, %v", i, book, got, err)
+		}
+		if reader.Line() != i+2 || len(record) == 0 {
+			t.Errorf("Row %d: expected line %d and its record, got %d, %v", i, i+2, reader.Line(), record)
+		}
+	}
+	if record, _, err := reader.Read(); !errors.Is(err, bookcsv.ErrRow) || len(record) != 5 || reader.Line() != 5 {
+		t.Errorf("Expected ErrRow for the year on line 5, got %v, %v on %d", record, err, reader.Line())
+	}
+	if _, _, err := reader.Read(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestReadMapped(t *testing.T) {
+	file := "Code,Title,Year,Written by\n9780306406157,Atomic,2022,Albert\n"
+	mapping := bookcsv.Mapping{"Code": "isbn", "Title": "name", "Year": "publish_year", "Written by": "authors"}
+	reader, err := bookcsv.NewReader(strings.NewReader(file), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, book, err := reader.Read()
+	expected := repositories.Book{ISBN: "9780306406157", Name: "Atomic", PublishYear: 2022, Authors: []string{"Albert"}}
+	if err != nil || !reflect.DeepEqual(book, expected) {
+		t.Errorf("Expected %v, got %v, %v", expected, book, err)
+	}
+}
+
+func TestBadHeader(t *testing.T) {
+	cases := []struct {
+		file    string
+		mapping bookcsv.Mapping
+	}{
+		{"", nil},
+		{"isbn,name\n", nil},
+		{"isbn,name,publish_year,Name\n", nil},
+		{"isbn,Title,publish_year\n", bookcsv.Mapping{"Title": "title"}},
+	}
+	for _, c := range cases {
+		if _, err := bookcsv.NewReader(strings.NewReader(c.file), c.mapping); !errors.Is(err, bookcsv.ErrHeader) {
+			t.Errorf("Expected ErrHeader for %q with %v, got %v", c.file, c.mapping, err)
+		}
+	}
+}
+
+func TestWriteReadsBack(t *testing.T) {
+	books := []repositories.Book{
+		{ISBN: "9780306406157", Name: "Atomic, \"2nd\"", PublishYear: 2022, Authors: []string{"Albert", "Victor"}},
+		{ISBN: "9780804429573", Name: "Short", PublishYear: 1998, Authors: []string{}},
+	}
+	var file strings.Builder
+	writer := bookcsv.NewWriter(&file)
+	for _, book := range books {
+		if err := writer.Write(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := bookcsv.NewReader(strings.NewReader(file.String()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, book := range books {
+		if _, got, err := reader.Read(); err != nil || !reflect.DeepEqual(got, book) {
+			t.Errorf("Expected %v back, got %v, %v", book, got, err)
+		}
+	}
+}
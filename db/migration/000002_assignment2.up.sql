@@ -1,6 +1,3 @@
-ALTER TABLE book
-ADD COLUMN id_author SERIAL;
-
 CREATE TABLE Author (
     id SERIAL PRIMARY KEY,
     name VARCHAR(100),
@@ -9,7 +6,14 @@ CREATE TABLE Author (
 
 INSERT INTO Author (name)
 SELECT DISTINCT author
-FROM book;
+FROM book
+WHERE author IS NOT NULL;
+
+ALTER TABLE book
+ADD COLUMN id_author INT;
+
+UPDATE book
+SET id_author = (SELECT MIN(a.id) FROM Author a WHERE a.name = book.author);
 
 ALTER TABLE book
 DROP COLUMN author;
@@ -17,4 +21,4 @@ DROP COLUMN author;
 ALTER TABLE book
 ADD CONSTRAINT fk_author
 FOREIGN KEY (id_author)
-REFERENCES Author(id);
\ No newline at end of file
+REFERENCES Author(id);
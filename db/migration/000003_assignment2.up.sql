@@ -5,14 +5,13 @@ CREATE TABLE book_author (
     FOREIGN KEY (id_author) REFERENCES author(id)
 );
 
-INSERT INTO author (name)
-SELECT DISTINCT id_author
-FROM book;
-
 INSERT INTO book_author (id_book, id_author)
-SELECT b.isbn, a.id
-FROM book b
-JOIN author a ON b.id_author = a.id;
+SELECT isbn, id_author
+FROM book
+WHERE id_author IS NOT NULL;
+
+ALTER TABLE book
+DROP CONSTRAINT fk_author;
 
 ALTER TABLE book
-DROP COLUMN id_author;
\ No newline at end of file
+DROP COLUMN id_author;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS author_name_search;
+
+DROP INDEX IF EXISTS book_name_search;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS book_name_search ON Book USING GIN (to_tsvector('simple', coalesce(name, '')));
+
+CREATE INDEX IF NOT EXISTS author_name_search ON Author USING GIN (to_tsvector('simple', coalesce(name, '')));
@@ -0,0 +1,3 @@
+-- version counts the writes to a book; the API hands it out as the ETag
+-- and checks it against If-Match.
+ALTER TABLE Book ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,13 @@
+-- Append-only log of book writes. Books are copied in as JSON rather than
+-- referenced, so the history outlives the rows it describes.
+CREATE TABLE IF NOT EXISTS book_history (
+    id BIGSERIAL PRIMARY KEY,
+    isbn VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    old_book JSONB,
+    new_book JSONB,
+    actor VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS book_history_isbn ON book_history (isbn, id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS book_deleted_at;
+
+ALTER TABLE Book DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+-- deleted_at tombstones deleted books until they are purged; reads skip
+-- the books that have one.
+ALTER TABLE Book ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS book_deleted_at ON Book (deleted_at);
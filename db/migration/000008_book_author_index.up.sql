@@ -0,0 +1,3 @@
+-- Every read of a book joins its authors through book_author, so bulk
+-- imports slow down with the size of the table without this.
+CREATE INDEX IF NOT EXISTS book_author_book ON book_author (id_book);
@@ -0,0 +1,232 @@
+package migration
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"server/logger"
+	"sort"
+	"strconv"
+)
+
+// Postgres holds the up/down scripts in this directory, the same ones the
+// `migrate` CLI reads with `-path db/migration`.
+//
+//go:embed *.sql
+var Postgres embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFiles embed.FS
+
+//go:embed mysql/*.sql
+var mysqlFiles embed.FS
+
+// SQLite holds the same schema versions written for SQLite.
+var SQLite = sub(sqliteFiles, "sqlite")
+
+// MySQL holds the same schema versions written for MySQL. MySQL commits DDL
+// statements implicitly, so a migration that fails half way through leaves
+// its earlier statements applied; the database is then left dirty at the
+// version of that migration.
+var MySQL = sub(mysqlFiles, "mysql")
+
+func sub(fsys fs.FS, dir string) fs.FS {
+	files, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
+
+// Table is the version tracking table. It has the same layout the `migrate`
+// CLI uses, so databases upgraded by hand are picked up as they are.
+const Table = "schema_migrations"
+
+var (
+	ErrDirty   = errors.New("database schema is dirty, fix it manually before starting the server")
+	ErrTooNew  = errors.New("database schema is newer than the latest known migration")
+	ErrMissing = errors.New("migration file is missing")
+)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+var L = logger.CreateLog()
+
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load reads a migration set and returns it sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = m
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%w: version %d has no up script", ErrMissing, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Latest returns the highest version in a migration set.
+func Latest(fsys fs.FS) (uint, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// Version returns the version recorded in the tracking table, creating the
+// table if it does not exist yet. A fresh database reports version 0.
+func Version(db *sql.DB) (uint, bool, error) {
+	cmd := `CREATE TABLE IF NOT EXISTS ` + Table + ` (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`
+	if _, err := db.Exec(cmd); err != nil {
+		return 0, false, err
+	}
+	var version int64
+	var dirty bool
+	err := db.QueryRow(`SELECT version, dirty FROM `+Table+` LIMIT 1`).Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
+
+// Up applies every pending migration of the set up to target, each one
+// inside its own transaction together with the version bump. A target of 0
+// means the latest known version. It returns the version the database is at
+// afterwards. A migration that fails leaves the database dirty.
+func Up(db *sql.DB, fsys fs.FS, target uint) (uint, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return 0, err
+	}
+	return up(db, migrations, target)
+}
+
+func up(db *sql.DB, migrations []Migration, target uint) (uint, error) {
+	current, dirty, err := Version(db)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return current, fmt.Errorf("%w (version %d)", ErrDirty, current)
+	}
+
+	var latest uint
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if current > latest {
+		return current, fmt.Errorf("%w (database at %d, latest is %d)", ErrTooNew, current, latest)
+	}
+	if target == 0 || target > latest {
+		target = latest
+	}
+	if current >= target {
+		L.Info(fmt.Sprintf("Database schema is at version %d, nothing to migrate", current))
+		return current, nil
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current || m.Version > target {
+			continue
+		}
+		L.Info(fmt.Sprintf("Migrating database schema to version %d (%s)", m.Version, m.Name))
+		if err := apply(db, m); err != nil {
+			return current, fmt.Errorf("migration %d: %w", m.Version, err)
+		}
+		current = m.Version
+	}
+	return current, nil
+}
+
+// apply runs m and records its version. The version is first recorded as
+// dirty, in a transaction of its own, and only marked clean together with
+// the script. A script that fails, or that commits half of its DDL before
+// failing as MySQL does, leaves the database dirty, and Up refuses to touch
+// it until it has been checked by hand.
+func apply(db *sql.DB, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := recordVersion(tx, m.Version, true); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(m.Up); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := recordVersion(tx, m.Version, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordVersion replaces the version in the tracking table.
+func recordVersion(tx *sql.Tx, version uint, dirty bool) error {
+	if _, err := tx.Exec(`DELETE FROM ` + Table); err != nil {
+		return err
+	}
+	// The version is inlined rather than bound so the statement does not
+	// depend on the placeholder style of the driver.
+	_, err := tx.Exec(fmt.Sprintf(`INSERT INTO %s (version, dirty) VALUES (%d, %t)`, Table, version, dirty))
+	return err
+}
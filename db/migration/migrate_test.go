@@ -0,0 +1,299 @@
+package migration_test
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"server/db/migration"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func NewMock() (*sql.DB, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	return db, mock
+}
+
+func expectVersion(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, dirty FROM schema_migrations LIMIT 1")).
+		WillReturnRows(rows)
+}
+
+// expectDirty expects version to be recorded as dirty before its script
+// runs.
+func expectDirty(mock sqlmock.Sqlmock, version uint) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf("INSERT INTO schema_migrations (version, dirty) VALUES (%d, true)", version))).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := migration.Load(migration.Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) < 3 {
+		t.Fatalf("Expected at least 3 migrations, got %d", len(migrations))
+	}
+	for i, m := range migrations {
+		if m.Version != uint(i+1) {
+			t.Errorf("Expected version %d at index %d, got %d", i+1, i, m.Version)
+		}
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("Migration %d is missing a script", m.Version)
+		}
+	}
+}
+
+func TestUpFromScratch(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+	migrations, _ := migration.Load(migration.Postgres)
+
+	expectVersion(mock, sqlmock.NewRows([]string{"version", "dirty"}))
+	for _, m := range migrations {
+		expectDirty(mock, m.Version)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(m.Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf("INSERT INTO schema_migrations (version, dirty) VALUES (%d, false)", m.Version))).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	version, err := migration.Up(db, migration.Postgres, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != migrations[len(migrations)-1].Version {
+		t.Errorf("Expected version %d, got %d", migrations[len(migrations)-1].Version, version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpToTarget(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+	migrations, _ := migration.Load(migration.Postgres)
+
+	expectVersion(mock, sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+	expectDirty(mock, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(migrations[1].Up)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations (version, dirty) VALUES (2, false)")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	version, err := migration.Up(db, migration.Postgres, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 2 {
+		t.Errorf("Expected version 2, got %d", version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpRollsBackOnError(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+	migrations, _ := migration.Load(migration.Postgres)
+
+	expectVersion(mock, sqlmock.NewRows([]string{"version", "dirty"}).AddRow(1, false))
+	expectDirty(mock, 2)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(migrations[1].Up)).WillReturnError(errors.New("boom"))
+	mock.ExpectRollback()
+
+	version, err := migration.Up(db, migration.Postgres, 0)
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+	if version != 1 {
+		t.Errorf("Expected version to stay at 1, got %d", version)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFailedMigrationLeavesDirty(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	migrations := fstest.MapFS{
+		"000001_books.up.sql":    {Data: []byte("CREATE TABLE Book (isbn VARCHAR(255) PRIMARY KEY);")},
+		"000001_books.down.sql":  {Data: []byte("DROP TABLE Book;")},
+		"000002_broken.up.sql":   {Data: []byte("ALTER TABLE Book ADD COLUMN name VARCHAR(255); ALTER TABLE Nothing ADD COLUMN x INT;")},
+		"000002_broken.down.sql": {Data: []byte("ALTER TABLE Book DROP COLUMN name;")},
+	}
+
+	version, err := migration.Up(db, migrations, 0)
+	if err == nil || version != 1 {
+		t.Fatalf("Expected migration 2 to fail at version 1, got %d, %v", version, err)
+	}
+	if version, dirty, err := migration.Version(db); err != nil || version != 2 || !dirty {
+		t.Errorf("Expected the database to be dirty at version 2, got %d, %v, %v", version, dirty, err)
+	}
+	if _, err := migration.Up(db, migrations, 0); !errors.Is(err, migration.ErrDirty) {
+		t.Errorf("Expected ErrDirty on the next Up, got %v", err)
+	}
+}
+
+func TestUpRefusesDirty(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	expectVersion(mock, sqlmock.NewRows([]string{"version", "dirty"}).AddRow(2, true))
+
+	_, err := migration.Up(db, migration.Postgres, 0)
+	if !errors.Is(err, migration.ErrDirty) {
+		t.Errorf("Expected ErrDirty, got %v", err)
+	}
+}
+
+func TestUpRefusesNewerSchema(t *testing.T) {
+	db, mock := NewMock()
+	defer db.Close()
+
+	expectVersion(mock, sqlmock.NewRows([]string{"version", "dirty"}).AddRow(999, false))
+
+	_, err := migration.Up(db, migration.Postgres, 0)
+	if !errors.Is(err, migration.ErrTooNew) {
+		t.Errorf("Expected ErrTooNew, got %v", err)
+	}
+}
+
+func TestSQLiteUpgradesData(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := migration.Up(db, migration.SQLite, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO Book (isbn, name, publish_year, author) VALUES ('133', 'Abcyx', 2021, 'a1'), ('129', 'Bo', 2009, 'a1')`); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := migration.Up(db, migration.SQLite, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := migration.Latest(migration.SQLite); version != latest {
+		t.Errorf("Expected version %d, got %d", latest, version)
+	}
+
+	var count int
+	err = db.QueryRow(`SELECT COUNT(*) FROM book_author ba JOIN Author a ON a.id = ba.id_author WHERE a.name = 'a1'`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("Expected both books linked to a1, got %d", count)
+	}
+}
+
+// TEST_POSTGRES_URL names a Postgres database the tests can create schemas
+// in. The Postgres tests are skipped without one.
+const TEST_POSTGRES_URL = "TEST_POSTGRES_URL"
+
+// openPostgres connects to TEST_POSTGRES_URL inside a schema of its own,
+// dropped when the test ends.
+func openPostgres(t *testing.T) *sql.DB {
+	url, exist := os.LookupEnv(TEST_POSTGRES_URL)
+	if !exist {
+		t.Skip(TEST_POSTGRES_URL + " is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The search path belongs to the connection, so there must be only one.
+	db.SetMaxOpenConns(1)
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec(`CREATE SCHEMA ` + schema + `; SET search_path TO ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+		db.Close()
+	})
+	return db
+}
+
+func TestPostgresUpgradesData(t *testing.T) {
+	db := openPostgres(t)
+	if _, err := migration.Up(db, migration.Postgres, 1); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Exec(`INSERT INTO Book (isbn, name, publish_year, author) VALUES ` +
+		`('133', 'Abcyx', 2021, 'a1'), ('129', 'Bo', 2009, 'a1'), ('140', 'Ce', 2011, 'a2'), ('150', 'Do', 2012, NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := migration.Up(db, migration.Postgres, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest, _ := migration.Latest(migration.Postgres); version != latest {
+		t.Errorf("Expected version %d, got %d", latest, version)
+	}
+
+	row, err := db.Query(`SELECT ba.id_book, a.name FROM book_author ba JOIN Author a ON a.id = ba.id_author ORDER BY ba.id_book`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer row.Close()
+	links := map[string]string{}
+	for row.Next() {
+		var isbn, author string
+		if err := row.Scan(&isbn, &author); err != nil {
+			t.Fatal(err)
+		}
+		links[isbn] = author
+	}
+	expected := map[string]string{"133": "a1", "129": "a1", "140": "a2"}
+	if fmt.Sprint(links) != fmt.Sprint(expected) {
+		t.Errorf("Expected books linked by author name as %v, got %v", expected, links)
+	}
+}
+
+func TestLoadMySQL(t *testing.T) {
+	mysql, err := migration.Latest(migration.MySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgres, _ := migration.Latest(migration.Postgres)
+	if mysql != postgres {
+		t.Errorf("Expected the MySQL set to reach version %d, got %d", postgres, mysql)
+	}
+}
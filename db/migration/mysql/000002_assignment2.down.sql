@@ -0,0 +1,14 @@
+ALTER TABLE Book
+ADD COLUMN author VARCHAR(255);
+
+UPDATE Book b
+JOIN Author a ON a.id = b.id_author
+SET b.author = a.name;
+
+ALTER TABLE Book
+DROP FOREIGN KEY fk_author;
+
+ALTER TABLE Book
+DROP COLUMN id_author;
+
+DROP TABLE Author;
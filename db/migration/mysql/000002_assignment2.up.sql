@@ -0,0 +1,24 @@
+CREATE TABLE Author (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(100),
+    birth_date DATE
+);
+
+INSERT INTO Author (name)
+SELECT DISTINCT author
+FROM Book
+WHERE author IS NOT NULL;
+
+ALTER TABLE Book
+ADD COLUMN id_author INT;
+
+UPDATE Book b
+SET b.id_author = (SELECT MIN(a.id) FROM Author a WHERE a.name = b.author);
+
+ALTER TABLE Book
+DROP COLUMN author;
+
+ALTER TABLE Book
+ADD CONSTRAINT fk_author
+FOREIGN KEY (id_author)
+REFERENCES Author(id);
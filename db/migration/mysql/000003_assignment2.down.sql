@@ -0,0 +1,12 @@
+ALTER TABLE Book
+ADD COLUMN id_author INT;
+
+UPDATE Book b
+SET b.id_author = (SELECT MIN(ba.id_author) FROM book_author ba WHERE ba.id_book = b.isbn);
+
+ALTER TABLE Book
+ADD CONSTRAINT fk_author
+FOREIGN KEY (id_author)
+REFERENCES Author(id);
+
+DROP TABLE book_author;
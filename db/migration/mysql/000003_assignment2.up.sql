@@ -0,0 +1,17 @@
+CREATE TABLE book_author (
+    id_book VARCHAR(255),
+    id_author INT,
+    FOREIGN KEY (id_book) REFERENCES Book(isbn),
+    FOREIGN KEY (id_author) REFERENCES Author(id)
+);
+
+INSERT INTO book_author (id_book, id_author)
+SELECT isbn, id_author
+FROM Book
+WHERE id_author IS NOT NULL;
+
+ALTER TABLE Book
+DROP FOREIGN KEY fk_author;
+
+ALTER TABLE Book
+DROP COLUMN id_author;
@@ -0,0 +1 @@
+DROP INDEX author_name ON Author;
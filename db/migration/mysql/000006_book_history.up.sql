@@ -0,0 +1,12 @@
+-- Append-only log of book writes. Books are copied in as JSON rather than
+-- referenced, so the history outlives the rows it describes.
+CREATE TABLE book_history (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    isbn VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    old_book JSON,
+    new_book JSON,
+    actor VARCHAR(255) NOT NULL,
+    changed_at DATETIME(6) NOT NULL,
+    INDEX book_history_isbn (isbn, id)
+);
@@ -0,0 +1,5 @@
+-- deleted_at tombstones deleted books until they are purged; reads skip
+-- the books that have one.
+ALTER TABLE Book ADD COLUMN deleted_at DATETIME(6) NULL;
+
+CREATE INDEX book_deleted_at ON Book (deleted_at);
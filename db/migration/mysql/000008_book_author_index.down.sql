@@ -0,0 +1,5 @@
+-- The foreign key needs an index on id_book, so the one MySQL made for it
+-- comes back before this one goes.
+CREATE INDEX id_book ON book_author (id_book);
+
+DROP INDEX book_author_book ON book_author;
@@ -0,0 +1,4 @@
+-- Every read of a book joins its authors through book_author, so bulk
+-- imports slow down with the size of the table without this. MySQL drops
+-- the index it made for the foreign key on id_book in favour of this one.
+CREATE INDEX book_author_book ON book_author (id_book);
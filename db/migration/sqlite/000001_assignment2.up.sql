@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS Book (
+    isbn VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255),
+    publish_year INT,
+    author VARCHAR(255)
+);
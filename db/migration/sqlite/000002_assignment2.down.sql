@@ -0,0 +1,17 @@
+CREATE TABLE book_v1 (
+    isbn VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255),
+    publish_year INT,
+    author VARCHAR(255)
+);
+
+INSERT INTO book_v1 (isbn, name, publish_year, author)
+SELECT b.isbn, b.name, b.publish_year, a.name
+FROM Book b
+LEFT JOIN Author a ON a.id = b.id_author;
+
+DROP TABLE Book;
+
+ALTER TABLE book_v1 RENAME TO Book;
+
+DROP TABLE Author;
@@ -0,0 +1,26 @@
+CREATE TABLE Author (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100),
+    birth_date DATE
+);
+
+INSERT INTO Author (name)
+SELECT DISTINCT author
+FROM Book
+WHERE author IS NOT NULL;
+
+-- SQLite cannot add a foreign key to an existing table, so Book is rebuilt.
+CREATE TABLE book_v2 (
+    isbn VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255),
+    publish_year INT,
+    id_author INTEGER REFERENCES Author(id)
+);
+
+INSERT INTO book_v2 (isbn, name, publish_year, id_author)
+SELECT b.isbn, b.name, b.publish_year, (SELECT MIN(a.id) FROM Author a WHERE a.name = b.author)
+FROM Book b;
+
+DROP TABLE Book;
+
+ALTER TABLE book_v2 RENAME TO Book;
@@ -0,0 +1,16 @@
+CREATE TABLE book_v2 (
+    isbn VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255),
+    publish_year INT,
+    id_author INTEGER REFERENCES Author(id)
+);
+
+INSERT INTO book_v2 (isbn, name, publish_year, id_author)
+SELECT b.isbn, b.name, b.publish_year, (SELECT MIN(ba.id_author) FROM book_author ba WHERE ba.id_book = b.isbn)
+FROM Book b;
+
+DROP TABLE book_author;
+
+DROP TABLE Book;
+
+ALTER TABLE book_v2 RENAME TO Book;
@@ -0,0 +1,29 @@
+CREATE TABLE book_author_v3 AS
+SELECT isbn AS id_book, id_author
+FROM Book
+WHERE id_author IS NOT NULL;
+
+CREATE TABLE book_v3 (
+    isbn VARCHAR(255) PRIMARY KEY,
+    name VARCHAR(255),
+    publish_year INT
+);
+
+INSERT INTO book_v3 (isbn, name, publish_year)
+SELECT isbn, name, publish_year
+FROM Book;
+
+DROP TABLE Book;
+
+ALTER TABLE book_v3 RENAME TO Book;
+
+CREATE TABLE book_author (
+    id_book VARCHAR(255) REFERENCES Book(isbn),
+    id_author INTEGER REFERENCES Author(id)
+);
+
+INSERT INTO book_author (id_book, id_author)
+SELECT id_book, id_author
+FROM book_author_v3;
+
+DROP TABLE book_author_v3;
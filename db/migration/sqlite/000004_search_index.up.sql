@@ -0,0 +1,3 @@
+-- Full-text search runs in the server for SQLite; this only speeds up
+-- the author name lookups it and the listings rely on.
+CREATE INDEX IF NOT EXISTS author_name ON Author (name);
@@ -0,0 +1 @@
+ALTER TABLE Book DROP COLUMN version;
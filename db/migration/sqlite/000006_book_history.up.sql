@@ -0,0 +1,13 @@
+-- Append-only log of book writes. Books are copied in as JSON rather than
+-- referenced, so the history outlives the rows it describes.
+CREATE TABLE book_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    isbn VARCHAR(255) NOT NULL,
+    action VARCHAR(16) NOT NULL,
+    old_book TEXT,
+    new_book TEXT,
+    actor VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX book_history_isbn ON book_history (isbn, id);
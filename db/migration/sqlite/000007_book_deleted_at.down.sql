@@ -0,0 +1,3 @@
+DROP INDEX book_deleted_at;
+
+ALTER TABLE Book DROP COLUMN deleted_at;
@@ -1,3 +1,5 @@
+//go:build ignore
+
 package main
 
 import (
@@ -32,19 +34,19 @@ func main() {
 	}
 
 	L.Info("Insert mock data")
-	_, e := db.Exec(`Call insert_book($1, $2 , $3, $4)`, "133", "Abcyx", 2021, "a1")
+	_, e := db.Exec(`Call insert_book($1, $2 , $3, $4)`, "9780134685991", "Abcyx", 2021, "a1")
 	if e != nil {
 		L.Error("Error while inserting mock data", e)
 	}
-	_, e1 := db.Exec(`Call insert_book($1, $2 , $3, $4)`, "129", "Bo", 2009, "a10")
+	_, e1 := db.Exec(`Call insert_book($1, $2 , $3, $4)`, "9781491950357", "Bo", 2009, "a10")
 	if e1 != nil {
 		L.Error("Error while inserting mock data", e1)
 	}
-	_, e2 := db.Exec(`Call insert_book($1, $2 , $3, $4)`, "273", "Atomic ", 2010, "a2")
+	_, e2 := db.Exec(`Call insert_book($1, $2 , $3, $4)`, "9780735211292", "Atomic ", 2010, "a2")
 	if e2 != nil {
 		L.Error("Error while inserting mock data", e2)
 	}
-	_, e3 := db.Exec(`Call insert_book($1, $2 , $3, $4)`, "103", "Atomic 3", 2023, "a4")
+	_, e3 := db.Exec(`Call insert_book($1, $2 , $3, $4)`, "9780593517611", "Atomic 3", 2023, "a4")
 	if e3 != nil {
 		L.Error("Error while inserting mock data", e3)
 	}
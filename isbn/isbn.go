@@ -0,0 +1,127 @@
+// Package isbn checks ISBN-10 and ISBN-13 numbers and turns them into the
+// canonical form books are stored under: the 13 digits of the ISBN-13,
+// without hyphens or spaces.
+package isbn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalid = errors.New("Invalid ISBN")
+
+var (
+	ErrLength    = fmt.Errorf("%w: must have 10 or 13 digits", ErrInvalid)
+	ErrCharacter = fmt.Errorf("%w: must only contain digits, hyphens and spaces, and X as the last digit of an ISBN-10", ErrInvalid)
+	ErrPrefix    = fmt.Errorf("%w: an ISBN-13 must start with 978 or 979", ErrInvalid)
+	ErrChecksum  = fmt.Errorf("%w: check digit does not match", ErrInvalid)
+)
+
+// Clean strips the hyphens and spaces ISBNs are usually printed with and
+// upper cases an ISBN-10 check digit of x.
+func Clean(s string) string {
+	s = strings.NewReplacer("-", "", " ", "").Replace(s)
+	return strings.ToUpper(s)
+}
+
+// Normalize validates s as an ISBN-10 or ISBN-13 and returns it as an
+// ISBN-13.
+func Normalize(s string) (string, error) {
+	s = Clean(s)
+	switch len(s) {
+	case 10:
+		if err := check10(s); err != nil {
+			return "", err
+		}
+		body := "978" + s[:9]
+		return body + string(checkDigit13(body)), nil
+	case 13:
+		if err := check13(s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	return "", ErrLength
+}
+
+// Valid reports whether s is an ISBN-10 or ISBN-13.
+func Valid(s string) bool {
+	_, err := Normalize(s)
+	return err == nil
+}
+
+// To10 returns the ISBN-10 of s. Only ISBN-13s starting with 978 have one.
+func To10(s string) (string, bool) {
+	s, err := Normalize(s)
+	if err != nil || !strings.HasPrefix(s, "978") {
+		return "", false
+	}
+	body := s[3:12]
+	return body + string(checkDigit10(body)), true
+}
+
+// Key returns the form s is looked up by: the ISBN-13 when s is valid and
+// s unchanged otherwise, so books stored before ISBNs were validated can
+// still be found.
+func Key(s string) string {
+	if canonical, err := Normalize(s); err == nil {
+		return canonical
+	}
+	return s
+}
+
+func check10(s string) error {
+	for i, r := range s {
+		if (r < '0' || r > '9') && !(r == 'X' && i == 9) {
+			return ErrCharacter
+		}
+	}
+	if checkDigit10(s[:9]) != s[9] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+func check13(s string) error {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return ErrCharacter
+		}
+	}
+	if !strings.HasPrefix(s, "978") && !strings.HasPrefix(s, "979") {
+		return ErrPrefix
+	}
+	if checkDigit13(s[:12]) != s[12] {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// checkDigit10 weighs the nine digits 10 down to 2; the check digit makes
+// the sum a multiple of 11, with X standing for 10.
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(body[i]-'0') * (10 - i)
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// checkDigit13 weighs the twelve digits alternately 1 and 3; the check
+// digit makes the sum a multiple of 10.
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(body[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,61 @@
+package isbn_test
+
+import (
+	"errors"
+	"server/isbn"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"9780306406157":     "9780306406157",
+		"978-0-306-40615-7": "9780306406157",
+		"0306406152":        "9780306406157",
+		"0-306-40615-2":     "9780306406157",
+		"080442957X":        "9780804429573",
+		"080442957x":        "9780804429573",
+		"979 10 90636 07 1": "9791090636071",
+	}
+	for input, expected := range cases {
+		got, err := isbn.Normalize(input)
+		if err != nil || got != expected {
+			t.Errorf("Normalize(%q) = %q, %v, expected %q", input, got, err, expected)
+		}
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	cases := map[string]error{
+		"":              isbn.ErrLength,
+		"133":           isbn.ErrLength,
+		"0306406153":    isbn.ErrChecksum,
+		"9780306406158": isbn.ErrChecksum,
+		"X306406152":    isbn.ErrCharacter,
+		"97803064061X7": isbn.ErrCharacter,
+		"9770306406157": isbn.ErrPrefix,
+	}
+	for input, expected := range cases {
+		_, err := isbn.Normalize(input)
+		if !errors.Is(err, expected) || !errors.Is(err, isbn.ErrInvalid) {
+			t.Errorf("Normalize(%q) returned %v, expected %v", input, err, expected)
+		}
+	}
+}
+
+func TestTo10(t *testing.T) {
+	if got, ok := isbn.To10("978-0-8044-2957-3"); !ok || got != "080442957X" {
+		t.Errorf("Expected 080442957X, got %q", got)
+	}
+	if _, ok := isbn.To10("9791090636071"); ok {
+		t.Error("Expected an ISBN-13 starting with 979 to have no ISBN-10")
+	}
+}
+
+func TestKey(t *testing.T) {
+	if got := isbn.Key("0-306-40615-2"); got != "9780306406157" {
+		t.Errorf("Expected the ISBN-13, got %q", got)
+	}
+	if got := isbn.Key("133"); got != "133" {
+		t.Errorf("Expected an invalid isbn to be kept as is, got %q", got)
+	}
+}
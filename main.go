@@ -2,22 +2,26 @@ package main
 
 import (
 	"net/http"
+	"os"
 
+	"server/logger"
 	// r "server/repositories"
 	Route "server/routers"
 )
 
+var L = logger.CreateLog()
+
 func main() {
+	if err := Route.Init(); err != nil {
+		L.Error("Error starting server:", err)
+		os.Exit(1)
+	}
 
 	// defer db.Close()
 	// if err := insertMockData(db); err != nil {
 	// 	log.Fatal(err)
 	// }
-	http.HandleFunc("GET /api/v1/books", Route.Get)
-	http.HandleFunc("GET /api/v1/books/range", Route.GetInRange)
-	http.HandleFunc("POST /api/v1/books/update", Route.Update)
-	http.HandleFunc("DELETE /api/v1/books/delete", Route.Delete)
-	http.HandleFunc("POST /api/v1/books/add", Route.Insert)
+	Route.Register(http.DefaultServeMux)
 
 	http.ListenAndServe(":8081", nil)
 }
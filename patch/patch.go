@@ -0,0 +1,290 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON values.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is a patch document that cannot be read.
+	ErrInvalidPatch = errors.New("Patch is not valid")
+	// ErrPath is a JSON Patch operation on a location that does not exist.
+	ErrPath = errors.New("Patch path does not exist")
+	// ErrTestFailed is a JSON Patch test operation that did not match.
+	ErrTestFailed = errors.New("Patch test failed")
+)
+
+// Merge applies the merge patch to doc: members of patch replace those of
+// doc, nulls remove them, and objects are merged member by member.
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target, changes any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+	return json.Marshal(merge(target, changes))
+}
+
+func merge(target, changes any) any {
+	members, ok := changes.(map[string]any)
+	if !ok {
+		return changes
+	}
+	result, ok := target.(map[string]any)
+	if !ok {
+		result = map[string]any{}
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(result, name)
+		} else {
+			result[name] = merge(result[name], value)
+		}
+	}
+	return result
+}
+
+// operation is one step of a JSON Patch. Value is kept raw so an explicit
+// null can be told apart from a missing value.
+type operation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Apply runs the operations of the JSON Patch patch on doc in order. If one
+// of them fails none of them apply.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	var ops []operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+	for i, op := range ops {
+		var err error
+		target, err = op.apply(target)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func (op operation) apply(doc any) (any, error) {
+	if op.Path == nil {
+		return nil, fmt.Errorf("%w: %q has no path", ErrInvalidPatch, op.Op)
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: %q has no value", ErrInvalidPatch, op.Op)
+		}
+		var value any
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+		}
+		switch op.Op {
+		case "add":
+			return add(doc, path, value)
+		case "replace":
+			if doc, _, err = remove(doc, path); err != nil {
+				return nil, err
+			}
+			return add(doc, path, value)
+		}
+		current, err := find(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("%w: %s", ErrTestFailed, *op.Path)
+		}
+		return doc, nil
+	case "remove":
+		doc, _, err = remove(doc, path)
+		return doc, err
+	case "move", "copy":
+		if op.From == nil {
+			return nil, fmt.Errorf("%w: %q has no from", ErrInvalidPatch, op.Op)
+		}
+		from, err := parsePointer(*op.From)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			value, err := find(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, clone(value))
+		}
+		if len(from) < len(path) && reflect.DeepEqual(from, path[:len(from)]) {
+			return nil, fmt.Errorf("%w: cannot move %s into itself", ErrInvalidPatch, *op.From)
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// index reads an array index, which must be below size. When end is set
+// "-", the position after the last element, is accepted too.
+func index(token string, size int, end bool) (int, error) {
+	if end && token == "-" {
+		return size, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || i > size || (i == size && !end) {
+		return 0, fmt.Errorf("%w: index %s", ErrPath, token)
+	}
+	return i, nil
+}
+
+func find(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			child, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: member %s", ErrPath, token)
+			}
+			doc = child
+		case []any:
+			i, err := index(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%w: %s is not in an object or array", ErrPath, token)
+		}
+	}
+	return doc, nil
+}
+
+// add returns doc with value added at path. Arrays are rebuilt rather than
+// changed in place, so the result has to replace doc.
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	token, last := path[0], len(path) == 1
+	switch node := doc.(type) {
+	case map[string]any:
+		if last {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: member %s", ErrPath, token)
+		}
+		child, err := add(child, path[1:], value)
+		node[token] = child
+		return node, err
+	case []any:
+		i, err := index(token, len(node), last)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			result := append([]any{}, node[:i]...)
+			result = append(result, value)
+			return append(result, node[i:]...), nil
+		}
+		node[i], err = add(node[i], path[1:], value)
+		return node, err
+	}
+	return nil, fmt.Errorf("%w: %s is not in an object or array", ErrPath, token)
+}
+
+// remove returns doc without the value at path, and that value.
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	token, last := path[0], len(path) == 1
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: member %s", ErrPath, token)
+		}
+		if last {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := remove(child, path[1:])
+		node[token] = child
+		return node, removed, err
+	case []any:
+		i, err := index(token, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			removed := node[i]
+			result := append([]any{}, node[:i]...)
+			return append(result, node[i+1:]...), removed, nil
+		}
+		child, removed, err := remove(node[i], path[1:])
+		node[i] = child
+		return node, removed, err
+	}
+	return nil, nil, fmt.Errorf("%w: %s is not in an object or array", ErrPath, token)
+}
+
+// clone deep copies a decoded JSON value, so a copied object does not
+// share its members with the original.
+func clone(value any) any {
+	switch node := value.(type) {
+	case map[string]any:
+		result := map[string]any{}
+		for name, child := range node {
+			result[name] = clone(child)
+		}
+		return result
+	case []any:
+		result := []any{}
+		for _, child := range node {
+			result = append(result, clone(child))
+		}
+		return result
+	}
+	return value
+}
@@ -0,0 +1,105 @@
+package patch_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"server/patch"
+	"testing"
+)
+
+func equalJSON(t *testing.T, got []byte, expected string) {
+	t.Helper()
+	var a, b any
+	if err := json.Unmarshal(got, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(expected), &b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+// The examples of RFC 7396, appendix A.
+func TestMerge(t *testing.T) {
+	cases := []struct{ doc, patch, expected string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		got, err := patch.Merge([]byte(c.doc), []byte(c.patch))
+		if err != nil {
+			t.Fatalf("Merge(%s, %s) failed: %v", c.doc, c.patch, err)
+		}
+		equalJSON(t, got, c.expected)
+	}
+	if _, err := patch.Merge([]byte(`{}`), []byte(`{`)); !errors.Is(err, patch.ErrInvalidPatch) {
+		t.Errorf("Expected ErrInvalidPatch, got %v", err)
+	}
+}
+
+// Mostly the examples of RFC 6902, appendix A.
+func TestApply(t *testing.T) {
+	cases := []struct{ doc, patch, expected string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`, `{"~1":10}`},
+		{`{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":null}]`, `{"foo":"bar","baz":null}`},
+	}
+	for _, c := range cases {
+		got, err := patch.Apply([]byte(c.doc), []byte(c.patch))
+		if err != nil {
+			t.Fatalf("Apply(%s, %s) failed: %v", c.doc, c.patch, err)
+		}
+		equalJSON(t, got, c.expected)
+	}
+}
+
+func TestApplyFails(t *testing.T) {
+	cases := []struct {
+		doc, patch string
+		expected   error
+	}{
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, patch.ErrTestFailed},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, patch.ErrPath},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, patch.ErrPath},
+		{`{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, patch.ErrPath},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":1}]`, patch.ErrPath},
+		{`{"foo":["bar"]}`, `[{"op":"remove","path":"/foo/01"}]`, patch.ErrPath},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, patch.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"frobnicate","path":"/foo"}]`, patch.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"foo"}]`, patch.ErrInvalidPatch},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, patch.ErrInvalidPatch},
+		{`{"foo":"bar"}`, `{"op":"remove","path":"/foo"}`, patch.ErrInvalidPatch},
+	}
+	for _, c := range cases {
+		if _, err := patch.Apply([]byte(c.doc), []byte(c.patch)); !errors.Is(err, c.expected) {
+			t.Errorf("Apply(%s, %s) returned %v, expected %v", c.doc, c.patch, err, c.expected)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package repositories
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+var ErrNoHistory = NewError(ErrBadRequest, "Reads as of a time need the book history, which this schema does not keep")
+
+// asOf works out the catalog at a moment from the changes of the books, in
+// the order they were made: a book is as the last change made by then left
+// it, or as the first change made since found it. Books that were never
+// changed while the history was kept are as they are now, which is exact
+// from the time the history started.
+func asOf(at time.Time, changes []Change, current []Book) []Book {
+	states := map[string]*Book{}
+	settled := map[string]bool{}
+	for _, change := range changes {
+		if !change.ChangedAt.After(at) {
+			states[change.ISBN] = change.After
+			settled[change.ISBN] = true
+			continue
+		}
+		if _, seen := states[change.ISBN]; !seen && !settled[change.ISBN] {
+			states[change.ISBN] = change.Before
+		}
+	}
+	books := []Book{}
+	for _, book := range current {
+		if _, changed := states[book.ISBN]; !changed {
+			books = append(books, book)
+		}
+	}
+	for _, book := range states {
+		if book != nil && book.DeletedAt == nil {
+			books = append(books, *book)
+		}
+	}
+	return books
+}
+
+// listAsOf cuts one page of the books matching filter out of the catalog
+// worked out by asOf.
+func listAsOf(books []Book, filter BookFilter, page PageRequest) (BookPage, error) {
+	page, after, err := page.normalize()
+	if err != nil {
+		return BookPage{}, err
+	}
+	matching := []Book{}
+	for _, book := range books {
+		if filter.matches(book) {
+			matching = append(matching, book)
+		}
+	}
+	result := paginate(matching, page, after)
+	if len(result.Books) == 0 {
+		return BookPage{}, ErrNoBooks
+	}
+	return result, nil
+}
+
+// ListBooksAsOf is ListBooks over the catalog as it was at the given time.
+// The catalog is worked out in the query, like asOf does it: each book as
+// the last change made to it by then left it, or as the first change made
+// since found it. Books without history are as they are now, so a book that
+// was never written since the history started is listed at any time, even
+// one before it was inserted. Filters and pages then apply as they do to
+// ListBooks.
+func (repo BookRepository) ListBooksAsOf(at time.Time, filter BookFilter, page PageRequest) (BookPage, error) {
+	if !repo.audited() {
+		return BookPage{}, ErrNoHistory
+	}
+	page, after, err := page.normalize()
+	if err != nil {
+		return BookPage{}, err
+	}
+	// The books of the past are snapshots, whose authors are only in doc.
+	author := filter.Author
+	filter.Author = ""
+	past := repo
+	past.withDeleted = true
+	where := past.filter(filter)
+	if author != "" {
+		where.add(`(b.doc IS NULL and `+repo.queries().authorFilter+` or b.doc IS NOT NULL and `+repo.Dialect.jsonHasAuthor("b.doc")+`)`, author, author)
+	}
+	from := ` from (` + repo.booksAsOf(where.arg(at.UTC())) + `) b`
+
+	result := BookPage{}
+	cmd := `SELECT COUNT(*)` + from + where.where()
+	L.Info("Querying " + cmd)
+	if err := repo.conn().QueryRow(cmd, where.args...).Scan(&result.Total); err != nil {
+		L.Error("Error ", err)
+		return BookPage{}, err
+	}
+	if after != nil {
+		where.keyset(page, after)
+	}
+	cmd = `SELECT b.isbn, b.doc` + from + where.where() + page.orderBy() + ` LIMIT ` + strconv.Itoa(page.Limit+1)
+	L.Info("Querying " + cmd)
+	row, err := repo.conn().Query(cmd, where.args...)
+	if err != nil {
+		L.Error("Error ", err)
+		return BookPage{}, err
+	}
+	books := []*Book{}
+	// current are the books without history, read from Book below.
+	current := map[string]*Book{}
+	isbns := []string{}
+	for row.Next() {
+		var isbn string
+		var doc sql.NullString
+		if err := row.Scan(&isbn, &doc); err != nil {
+			row.Close()
+			L.Error("Error ", err)
+			return BookPage{}, err
+		}
+		book, err := parseBook(doc)
+		if err != nil {
+			row.Close()
+			L.Error("Error ", err)
+			return BookPage{}, err
+		}
+		if book == nil {
+			book = &Book{ISBN: isbn}
+			current[isbn] = book
+			isbns = append(isbns, isbn)
+		}
+		books = append(books, book)
+	}
+	row.Close()
+	if err := row.Err(); err != nil {
+		L.Error("Error ", err)
+		return BookPage{}, err
+	}
+	if len(books) == 0 {
+		return BookPage{}, ErrNoBooks
+	}
+
+	if len(isbns) > 0 {
+		stored, err := repo.getByISBNs(isbns)
+		if err != nil {
+			return BookPage{}, err
+		}
+		for _, book := range stored {
+			*current[book.ISBN] = book
+		}
+	}
+	more := len(books) > page.Limit
+	if more {
+		books = books[:page.Limit]
+	}
+	for _, book := range books {
+		result.Books = append(result.Books, *book)
+	}
+	if more {
+		result.NextCursor = page.next(result.Books[len(result.Books)-1])
+	}
+	return result, nil
+}
+
+// booksAsOf selects the catalog as it was at the time in the placeholder at,
+// with the columns of Book the listings filter and sort on, and doc holding
+// the snapshot of the books that come from the history.
+func (repo BookRepository) booksAsOf(at string) string {
+	dialect := repo.Dialect
+	current := ` UNION ALL SELECT b.isbn, b.name, b.publish_year, NULL from Book b where b.isbn NOT IN (SELECT isbn from book_history)`
+	if repo.softDeletes() {
+		current += ` and b.deleted_at IS NULL`
+	}
+	return `SELECT h.isbn, ` + dialect.jsonText("h.book", "name") + ` AS name, ` + dialect.jsonInt("h.book", "publish_year") + ` AS publish_year, h.book AS doc from (` +
+		`SELECT isbn, new_book AS book from book_history where id IN (SELECT MAX(id) from book_history where changed_at <= ` + at + ` GROUP BY isbn)` +
+		` UNION ALL SELECT isbn, old_book from book_history where id IN (SELECT MIN(id) from book_history where changed_at > ` + at + ` GROUP BY isbn)` +
+		` and isbn NOT IN (SELECT isbn from book_history where changed_at <= ` + at + `)` +
+		`) h where h.book IS NOT NULL and ` + dialect.jsonText("h.book", "deleted_at") + ` IS NULL` +
+		current
+}
+
+// jsonText reads field out of the JSON object in column as text.
+func (dialect Dialect) jsonText(column, field string) string {
+	switch {
+	case dialect.isPostgres():
+		return column + `->>'` + field + `'`
+	case dialect.Name == MySQL.Name:
+		return column + `->>'$.` + field + `'`
+	}
+	return `json_extract(` + column + `, '$.` + field + `')`
+}
+
+// jsonInt reads field out of the JSON object in column as a number.
+func (dialect Dialect) jsonInt(column, field string) string {
+	switch {
+	case dialect.isPostgres():
+		return `(` + dialect.jsonText(column, field) + `)::int`
+	case dialect.Name == MySQL.Name:
+		return `CAST(` + dialect.jsonText(column, field) + ` AS SIGNED)`
+	}
+	return dialect.jsonText(column, field)
+}
+
+// jsonHasAuthor is the clause that holds when the book in column has the
+// author in %s.
+func (dialect Dialect) jsonHasAuthor(column string) string {
+	switch {
+	case dialect.isPostgres():
+		return column + `->'authors' @> jsonb_build_array(%s::text)`
+	case dialect.Name == MySQL.Name:
+		return `JSON_CONTAINS(` + column + `->'$.authors', JSON_QUOTE(%s))`
+	}
+	return `EXISTS (SELECT 1 from json_each(` + column + `, '$.authors') where value=%s)`
+}
+
+func (repo MemoryBookRepository) ListBooksAsOf(at time.Time, filter BookFilter, page PageRequest) (BookPage, error) {
+	var books []Book
+	repo.db.view(repo.tx, func(data *memoryData) error {
+		current := data.filter(func(data *memoryData, stored memoryBook) bool { return stored.deletedAt == nil })
+		books = asOf(at, data.history, current)
+		return nil
+	})
+	return listAsOf(books, filter, page)
+}
@@ -0,0 +1,238 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+var (
+	ErrAuthorNotFound = NewError(ErrNotFound, "No author found")
+	ErrAuthorHasBooks = NewError(ErrConflict, "Author still has books")
+)
+
+// Date is a calendar date that travels as "2006-01-02" in JSON.
+type Date struct {
+	time.Time
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Format(DateLayout))
+}
+
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	t, err := time.Parse(DateLayout, strings.TrimSpace(value))
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+type Author struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	BirthDate *Date  `json:"birth_date"`
+}
+
+type AuthorRepository struct {
+	DB      DBTX
+	Table   string
+	Version uint
+	// Dialect is only consulted for how Insert reads the new id; DB is
+	// expected to be wrapped by it already.
+	Dialect Dialect
+	// index is the search index of the books, which holds author names
+	// too, so renames and deletes have to refresh it.
+	index *searchCache
+	// pool starts the transactions of Transaction, and tx is the one the
+	// repository is bound to, if any.
+	pool *sql.DB
+	tx   *sql.Tx
+}
+
+func NewAuthorRepository(db DBTX, version uint) *AuthorRepository {
+	pool, _ := db.(*sql.DB)
+	return &AuthorRepository{
+		DB:      db,
+		Table:   "Author",
+		Version: version,
+		pool:    pool,
+	}
+}
+
+// Authors returns the author repository of the same database, sharing the
+// search index of the books.
+func (repo BookRepository) Authors() *AuthorRepository {
+	authors := NewAuthorRepository(repo.Dialect.Wrap(repo.DB), repo.Version)
+	authors.Dialect = repo.Dialect
+	authors.index = repo.index
+	authors.pool = repo.DB
+	return authors
+}
+
+// Transaction runs fn with a store bound to a single transaction,
+// committing when fn succeeds and rolling back otherwise. Calls made on a
+// repository that is already inside a transaction join it, and ones made
+// over a DB that cannot begin a transaction run fn as is.
+func (repo AuthorRepository) Transaction(fn func(store AuthorStore) error) error {
+	if repo.tx != nil || repo.pool == nil {
+		return fn(repo)
+	}
+	tx, err := repo.pool.Begin()
+	if err != nil {
+		L.Error("Error ", err)
+		return err
+	}
+	repo.tx = tx
+	repo.DB = repo.Dialect.Wrap(tx)
+	if err := fn(repo); err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer repo.index.invalidate()
+	return tx.Commit()
+}
+
+// supported reports whether the schema has an Author table at all.
+func (repo AuthorRepository) supported() error {
+	if schemaOf(repo.Version) == SchemaV1 {
+		return ErrNoAuthors
+	}
+	return nil
+}
+
+func birthDate(date *Date) any {
+	if date == nil {
+		return nil
+	}
+	return date.Time
+}
+
+func scanAuthor(row interface{ Scan(...any) error }) (Author, error) {
+	author := Author{}
+	var birth sql.NullTime
+	err := row.Scan(&author.ID, &author.Name, &birth)
+	if birth.Valid {
+		author.BirthDate = &Date{birth.Time}
+	}
+	return author, err
+}
+
+func (repo AuthorRepository) GetAll() ([]Author, error) {
+	if err := repo.supported(); err != nil {
+		return nil, err
+	}
+	authors := []Author{}
+	cmd := `SELECT id,name,birth_date from Author ORDER BY id`
+	L.Info("Querying " + cmd)
+	row, err := repo.DB.Query(cmd)
+	if err != nil {
+		L.Error("Error ", err)
+		return nil, err
+	}
+	L.Info("Query successfully")
+	defer row.Close()
+
+	for row.Next() {
+		author, err := scanAuthor(row)
+		if err != nil {
+			L.Error("Error ", err)
+			return nil, err
+		}
+		authors = append(authors, author)
+	}
+
+	return authors, row.Err()
+}
+
+func (repo AuthorRepository) GetByID(id int) (Author, error) {
+	if err := repo.supported(); err != nil {
+		return Author{}, err
+	}
+	cmd := `SELECT id,name,birth_date from Author where id=$1`
+	L.Info("Querying " + cmd)
+	author, err := scanAuthor(repo.DB.QueryRow(cmd, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			L.Error("Error ", ErrAuthorNotFound)
+			return Author{}, ErrAuthorNotFound
+		}
+		L.Error("Error ", err)
+		return Author{}, ErrSomethingWentWrong
+	}
+	L.Info("Query successfully")
+	return author, nil
+}
+
+func (repo AuthorRepository) GetByName(name string) (Author, error) {
+	if err := repo.supported(); err != nil {
+		return Author{}, err
+	}
+	cmd := `SELECT id,name,birth_date from Author where name=$1 ORDER BY id LIMIT 1`
+	L.Info("Querying " + cmd)
+	author, err := scanAuthor(repo.DB.QueryRow(cmd, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			L.Error("Error ", ErrAuthorNotFound)
+			return Author{}, ErrAuthorNotFound
+		}
+		L.Error("Error ", err)
+		return Author{}, ErrSomethingWentWrong
+	}
+	L.Info("Query successfully")
+	return author, nil
+}
+
+func (repo AuthorRepository) Insert(author Author) (Author, error) {
+	if err := repo.supported(); err != nil {
+		return author, err
+	}
+	if repo.Dialect.LastInsertID {
+		cmd := "INSERT INTO Author (name, birth_date) VALUES ($1, $2)"
+		res, err := repo.DB.Exec(cmd, author.Name, birthDate(author.BirthDate))
+		if err != nil {
+			return author, err
+		}
+		id, err := res.LastInsertId()
+		author.ID = int(id)
+		return author, err
+	}
+	cmd := "INSERT INTO Author (name, birth_date) VALUES ($1, $2) RETURNING id"
+	err := repo.DB.QueryRow(cmd, author.Name, birthDate(author.BirthDate)).Scan(&author.ID)
+	return author, err
+}
+
+func (repo AuthorRepository) Update(author Author) (sql.Result, error) {
+	if err := repo.supported(); err != nil {
+		return nil, err
+	}
+	cmd := "UPDATE Author SET name = $1, birth_date = $2 WHERE id = $3"
+	res, err := repo.DB.Exec(cmd, author.Name, birthDate(author.BirthDate), author.ID)
+	if err == nil {
+		repo.index.invalidate()
+	}
+	return res, err
+}
+
+func (repo AuthorRepository) Delete(id int) (sql.Result, error) {
+	if err := repo.supported(); err != nil {
+		return nil, err
+	}
+	cmd := "DELETE FROM Author WHERE id = $1"
+	res, err := repo.DB.Exec(cmd, id)
+	if isForeignKeyViolation(err) {
+		return res, ErrAuthorHasBooks
+	}
+	if err == nil {
+		repo.index.invalidate()
+	}
+	return res, err
+}
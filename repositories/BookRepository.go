@@ -3,30 +3,74 @@ package repositories
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"os"
+	"server/db/migration"
 	"server/logger"
+	"strconv"
+	"strings"
+	"time"
 
+	_ "github.com/go-sql-driver/mysql"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 const DB_URL = "DB_URL"
 
+// DB_SCHEMA_VERSION optionally pins the schema version the server migrates
+// up to, e.g. 1 or 2 to keep running against an older layout.
+const DB_SCHEMA_VERSION = "DB_SCHEMA_VERSION"
+
 type Book struct {
-	ISBN        string `json:"isbn"`
-	Name        string `json:"name"`
-	PublishYear int    `json:"publish_year"`
-	Author      string `json:"author"`
+	ISBN        string   `json:"isbn"`
+	Name        string   `json:"name"`
+	PublishYear int      `json:"publish_year"`
+	Authors     []string `json:"authors"`
+	// Version counts the writes to the book, starting at 1. It is 0 before
+	// the schema has a version column. A write that sets it only goes
+	// through if the book is still at that version.
+	Version int `json:"version,omitempty"`
+	// DeletedAt is when the book was deleted, for the reads that include
+	// deleted books.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// DBTX is what the queries need from either a *sql.DB or a *sql.Tx.
+type DBTX interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 type BookRepository struct {
 	DB    *sql.DB
 	Table string
+	// Version is the applied schema version and picks the query set, see
+	// Schema.go. Zero means unknown and is served with the v3 layout.
+	Version uint
+	// Dialect adapts the queries to the database. The zero value is Postgres.
+	Dialect Dialect
+	tx      *sql.Tx
+	// actor is recorded in book_history as the author of the writes.
+	actor string
+	// withDeleted makes the reads include deleted books.
+	withDeleted bool
+	// index backs Search when the database cannot search itself.
+	index *searchCache
 }
 
 var L = logger.CreateLog()
 
-func ConnectDB() (*sql.DB, error) {
+var (
+	ErrNoBooks      = NewError(ErrNotFound, "No books found")
+	ErrBookNotFound = NewError(ErrNotFound, "No Book found")
+	ErrBookExists   = NewError(ErrConflict, "Book already exists")
+)
+
+// DatabaseURL reads DB_URL from the environment, falling back to .env.
+func DatabaseURL() string {
 	var url string
 	err := godotenv.Load()
 	if err != nil {
@@ -37,146 +81,608 @@ func ConnectDB() (*sql.DB, error) {
 	} else {
 		L.Error("Error loading .env file:", err)
 	}
+	return url
+}
 
-	db, err := sql.Open("postgres", url)
+// ConnectDB opens the database named by DB_URL and migrates it with the
+// migration set of its dialect.
+func ConnectDB() (*sql.DB, Dialect, error) {
+	dialect, dsn := ParseURL(DatabaseURL())
+	db, err := sql.Open(dialect.Driver, dsn)
 	if err != nil {
 		L.Error("Error open db:", err)
+		return nil, dialect, err
 	}
-	return db, err
+	if dialect.Name == SQLite.Name {
+		// SQLite allows a single writer; one connection avoids "database is
+		// locked" errors and keeps ":memory:" databases from splitting.
+		db.SetMaxOpenConns(1)
+	}
+
+	var target uint
+	if value, exist := os.LookupEnv(DB_SCHEMA_VERSION); exist {
+		version, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			db.Close()
+			return nil, dialect, fmt.Errorf("invalid %s %q: %w", DB_SCHEMA_VERSION, value, err)
+		}
+		target = uint(version)
+	}
+	version, err := migration.Up(db, dialect.Migrations, target)
+	if err != nil {
+		L.Error("Error migrating db:", err)
+		db.Close()
+		return nil, dialect, err
+	}
+	L.Info(fmt.Sprintf("Database schema version %d (%s)", version, dialect.Name))
+	return db, dialect, nil
 }
 
-func NewBookRepository() (*BookRepository, error) {
-	db, err := ConnectDB()
+func NewBookRepository(db *sql.DB, dialect Dialect) (*BookRepository, error) {
+	version, err := DetectVersion(db)
 	if err != nil {
+		L.Error("Error detecting schema version:", err)
 		return nil, err
 	}
+	L.Info(fmt.Sprintf("Serving books with the v%d query set", schemaOf(version)))
 	return &BookRepository{
-		DB:    db,
-		Table: "Book",
+		DB:      db,
+		Table:   "Book",
+		Version: version,
+		Dialect: dialect,
+		index:   &searchCache{ttl: SearchCacheTTL},
 	}, nil
 }
 
-func (repo BookRepository) GetAllBooks() ([]Book, error) {
-	books := []Book{}
-	cmd := `SELECT isbn,name,author,publish_year from Book`
-	L.Info("Querying " + cmd)
-	row, err := repo.DB.Query(cmd)
-	L.Info("Query successfully")
+func (repo BookRepository) conn() DBTX {
+	if repo.tx != nil {
+		return repo.Dialect.Wrap(repo.tx)
+	}
+	return repo.Dialect.Wrap(repo.DB)
+}
+
+// Transaction runs fn with a store bound to a single transaction,
+// committing when fn succeeds and rolling back otherwise. Calls made on a
+// repository that is already inside a transaction join it.
+func (repo BookRepository) Transaction(fn func(store BookStore) error) error {
+	return repo.transaction(func(repo BookRepository) error {
+		return fn(repo)
+	})
+}
 
+func (repo BookRepository) transaction(fn func(repo BookRepository) error) error {
+	if repo.tx != nil {
+		return fn(repo)
+	}
+	tx, err := repo.DB.Begin()
+	if err != nil {
+		L.Error("Error ", err)
+		return err
+	}
+	repo.tx = tx
+	if err := fn(repo); err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer repo.index.invalidate()
+	return tx.Commit()
+}
+
+// scanBooks folds the one-row-per-author result of a select back into
+// one Book per isbn, keeping the order in which books first appear. A fifth
+// column is the version of the book and a sixth when it was deleted.
+func scanBooks(row *sql.Rows) ([]Book, error) {
+	columns, err := row.Columns()
+	if err != nil {
+		return nil, err
+	}
+	books := []Book{}
+	index := map[string]int{}
 	for row.Next() {
-		book := Book{}
-		err := row.Scan(&book.ISBN, &book.Name, &book.Author, &book.PublishYear)
+		book, author, err := scanBook(row, len(columns))
 		if err != nil {
-			L.Error("Error", err)
-			return nil, errors.New("No books found")
+			return nil, err
+		}
+		i, ok := index[book.ISBN]
+		if !ok {
+			books = append(books, book)
+			i = len(books) - 1
+			index[book.ISBN] = i
 		}
-		books = append(books, book)
+		if author != "" {
+			books[i].Authors = append(books[i].Authors, author)
+		}
+	}
+	return books, row.Err()
+}
+
+// scanBook reads the current row of a book select: the book, without
+// authors, and the one author the row holds, "" when it holds none.
+func scanBook(row *sql.Rows, columns int) (Book, string, error) {
+	book := Book{Authors: []string{}}
+	var author sql.NullString
+	var deletedAt sql.NullTime
+	dest := []any{&book.ISBN, &book.Name, &book.PublishYear, &author, &book.Version, &deletedAt}
+	if err := row.Scan(dest[:columns]...); err != nil {
+		return book, "", err
+	}
+	if deletedAt.Valid {
+		book.DeletedAt = &deletedAt.Time
+	}
+	return book, author.String, nil
+}
+
+func (repo BookRepository) GetAllBooks() ([]Book, error) {
+	where := repo.filter(BookFilter{})
+	cmd := repo.queries().selectBooks + where.where()
+	L.Info("Querying " + cmd)
+	row, err := repo.conn().Query(cmd, where.args...)
+	if err != nil {
+		L.Error("Error ", err)
+		return nil, err
+	}
+	L.Info("Query successfully")
+	defer row.Close()
+
+	books, err := scanBooks(row)
+	if err != nil {
+		L.Error("Error", err)
+		return nil, err
 	}
 
 	if len(books) == 0 {
 		L.Error("Error ", errors.New("no books found"))
-		return nil, errors.New("No books found")
+		return nil, ErrNoBooks
 	}
-	defer row.Close()
 	return books, err
 }
 
 func (repo BookRepository) GetByISBN(isbn string) (Book, error) {
-	book := Book{}
-	cmd := `SELECT isbn,name,author,publish_year from Book where "isbn"=$1`
+	where := repo.filter(BookFilter{ISBN: isbn})
+	cmd := repo.queries().selectBooks + where.where()
 	L.Info("Querying " + cmd)
-	row := repo.DB.QueryRow(cmd, isbn)
+	row, err := repo.conn().Query(cmd, where.args...)
+	if err != nil {
+		L.Error("Error ", err)
+		return Book{}, ErrSomethingWentWrong
+	}
 	L.Info("Query successfully")
-	err := row.Scan(&book.ISBN, &book.Name, &book.Author, &book.PublishYear)
+	defer row.Close()
 
+	books, err := scanBooks(row)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			L.Error("Error ", errors.New("no books found"))
-			return book, errors.New("No Book found")
-		}
 		L.Error("Error ", err)
-		return book, errors.New("Something went wrong")
+		return Book{}, ErrSomethingWentWrong
+	}
+	if len(books) == 0 {
+		L.Error("Error ", errors.New("no books found"))
+		return Book{}, ErrBookNotFound
 	}
 
-	return book, err
+	return books[0], nil
 }
 
 func (repo BookRepository) GetByAuthor(author string) ([]Book, error) {
-	books := []Book{}
-	cmd := `SELECT isbn,name,author,publish_year from Book where "author"=$1`
+	filter := repo.filter(BookFilter{Author: author})
+	cmd := repo.queries().selectBooks + filter.where()
 	L.Info("Querying " + cmd)
-	row, err := repo.DB.Query(cmd, author)
-	L.Info("Query successfully")
-
+	row, err := repo.conn().Query(cmd, filter.args...)
 	if err != nil {
 		L.Error("Error ", err)
-		return nil, errors.New("No books found")
+		return nil, err
 	}
+	L.Info("Query successfully")
 	defer row.Close()
-	for row.Next() {
-		book := Book{}
-		err := row.Scan(&book.ISBN, &book.Name, &book.Author, &book.PublishYear)
-		if err != nil {
-			L.Error("Error ", err)
-			return nil, errors.New("No books found")
-		}
-		books = append(books, book)
 
+	books, err := scanBooks(row)
+	if err != nil {
+		L.Error("Error ", err)
+		return nil, err
 	}
 
 	if len(books) == 0 {
 		L.Error("Error ", errors.New("no books found"))
-		return nil, errors.New("No books found")
+		return nil, ErrNoBooks
 	}
 
 	return books, err
 }
 
 func (repo BookRepository) GetInRange(year1, year2 int) ([]Book, error) {
-	books := []Book{}
-	cmd := `SELECT isbn,name,author,publish_year from Book where "publish_year"<=$2 and "publish_year">=$1`
+	cmd := repo.queries().selectBooks + ` where b.publish_year<=$2 and b.publish_year>=$1`
+	if live := repo.live(); live != "" {
+		cmd += ` and ` + live
+	}
 	L.Info("Querying " + cmd)
-	row, err := repo.DB.Query(cmd, year1, year2)
+	row, err := repo.conn().Query(cmd, year1, year2)
+	if err != nil {
+		L.Error("Error ", err)
+		return nil, err
+	}
 	L.Info("Query successfully")
+	defer row.Close()
 
+	books, err := scanBooks(row)
 	if err != nil {
 		L.Error("Error ", err)
 		return nil, err
 	}
-	defer row.Close()
+
+	if len(books) == 0 {
+		L.Error("Error ", errors.New("no books found"))
+		return nil, ErrNoBooks
+	}
+
+	return books, err
+}
+
+// ListBooks returns one page of the books matching filter. The page is
+// picked over Book alone and the authors of its books are read afterwards,
+// so books with several authors still count once towards the limit.
+func (repo BookRepository) ListBooks(filter BookFilter, page PageRequest) (BookPage, error) {
+	page, after, err := page.normalize()
+	if err != nil {
+		return BookPage{}, err
+	}
+	result := BookPage{}
+	where := repo.filter(filter)
+	cmd := `SELECT COUNT(*) ` + repo.queries().fromBooks + where.where()
+	L.Info("Querying " + cmd)
+	if err := repo.conn().QueryRow(cmd, where.args...).Scan(&result.Total); err != nil {
+		L.Error("Error ", err)
+		return BookPage{}, err
+	}
+
+	if after != nil {
+		where.keyset(page, after)
+	}
+	cmd = `SELECT b.isbn ` + repo.queries().fromBooks + where.where() + page.orderBy() + ` LIMIT ` + strconv.Itoa(page.Limit+1)
+	L.Info("Querying " + cmd)
+	row, err := repo.conn().Query(cmd, where.args...)
+	if err != nil {
+		L.Error("Error ", err)
+		return BookPage{}, err
+	}
+	isbns := []string{}
 	for row.Next() {
-		book := Book{}
-		err := row.Scan(&book.ISBN, &book.Name, &book.Author, &book.PublishYear)
-		if err != nil {
+		var isbn string
+		if err := row.Scan(&isbn); err != nil {
+			row.Close()
 			L.Error("Error ", err)
-			return nil, err
+			return BookPage{}, err
 		}
-		books = append(books, book)
+		isbns = append(isbns, isbn)
+	}
+	row.Close()
+	if err := row.Err(); err != nil {
+		L.Error("Error ", err)
+		return BookPage{}, err
+	}
+	if len(isbns) == 0 {
+		L.Error("Error ", errors.New("no books found"))
+		return BookPage{}, ErrNoBooks
+	}
 
+	more := len(isbns) > page.Limit
+	if more {
+		isbns = isbns[:page.Limit]
 	}
+	books, err := repo.getByISBNs(isbns)
+	if err != nil {
+		return BookPage{}, err
+	}
+	result.Books = books
+	if more {
+		result.NextCursor = page.next(books[len(books)-1])
+	}
+	return result, nil
+}
 
-	if len(books) == 0 {
-		L.Error("Error ", errors.New("no books found"))
-		return nil, errors.New("No books found")
+// getByISBNs reads the given books in the order of isbns.
+func (repo BookRepository) getByISBNs(isbns []string) ([]Book, error) {
+	marks := []string{}
+	args := []any{}
+	for i, isbn := range isbns {
+		marks = append(marks, "$"+strconv.Itoa(i+1))
+		args = append(args, isbn)
+	}
+	cmd := repo.queries().selectBooks + ` where b.isbn IN (` + strings.Join(marks, ",") + `)`
+	L.Info("Querying " + cmd)
+	row, err := repo.conn().Query(cmd, args...)
+	if err != nil {
+		L.Error("Error ", err)
+		return nil, err
+	}
+	defer row.Close()
+	found, err := scanBooks(row)
+	if err != nil {
+		L.Error("Error ", err)
+		return nil, err
 	}
+	byISBN := map[string]Book{}
+	for _, book := range found {
+		byISBN[book.ISBN] = book
+	}
+	books := []Book{}
+	for _, isbn := range isbns {
+		if book, ok := byISBN[isbn]; ok {
+			books = append(books, book)
+		}
+	}
+	return books, nil
+}
 
-	return books, err
+// authorID returns the id of the author with the given name, creating the
+// author when it does not exist yet.
+func (repo BookRepository) authorID(author string) (int, error) {
+	authors := AuthorRepository{DB: repo.conn(), Table: "Author", Version: repo.Version, Dialect: repo.Dialect}
+	existing, err := authors.GetByName(author)
+	if err == nil {
+		return existing.ID, nil
+	}
+	if err != ErrAuthorNotFound {
+		return 0, err
+	}
+	created, err := authors.Insert(Author{Name: author})
+	return created.ID, err
 }
 
-func (repo BookRepository) Update(isbn, name, author string, publish_year int) (sql.Result, error) {
-	cmd := "UPDATE Book SET name = $1, publish_year = $2, author = $3 WHERE isbn = $4"
-	res, err := repo.DB.Exec(cmd, name, publish_year, author, isbn)
+// linkAuthors points isbn at each of the named authors in book_author.
+func (repo BookRepository) linkAuthors(isbn string, authors []string) error {
+	seen := map[string]bool{}
+	for _, author := range authors {
+		if seen[author] {
+			continue
+		}
+		seen[author] = true
+		authorID, err := repo.authorID(author)
+		if err != nil {
+			return err
+		}
+		cmd := "INSERT INTO book_author (id_book, id_author) VALUES ($1, $2)"
+		if _, err := repo.conn().Exec(cmd, isbn, authorID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repo BookRepository) Update(isbn, name string, authors []string, publish_year int) (sql.Result, error) {
+	var res sql.Result
+	err := repo.write(isbn, func(repo BookRepository) error {
+		var err error
+		switch repo.schema() {
+		case SchemaV1:
+			author, err := singleAuthor(authors, false)
+			if err != nil {
+				return err
+			}
+			cmd := "UPDATE Book SET name = $1, publish_year = $2, author = $3 WHERE isbn = $4"
+			if res, err = repo.conn().Exec(cmd, name, publish_year, nullString(author), isbn); err != nil {
+				return err
+			}
+			return repo.updated(res, isbn)
+		case SchemaV2:
+			author, err := singleAuthor(authors, true)
+			if err != nil {
+				return err
+			}
+			authorID, err := repo.authorID(author)
+			if err != nil {
+				return err
+			}
+			cmd := "UPDATE Book SET name = $1, publish_year = $2, id_author = $3 WHERE isbn = $4"
+			if res, err = repo.conn().Exec(cmd, name, publish_year, authorID, isbn); err != nil {
+				return err
+			}
+			return repo.updated(res, isbn)
+		}
+		cmd := "UPDATE Book SET name = $1, publish_year = $2 WHERE isbn = $3"
+		if repo.versioned() {
+			cmd = "UPDATE Book SET name = $1, publish_year = $2, version = version + 1 WHERE isbn = $3"
+		}
+		res, err = repo.conn().Exec(cmd, name, publish_year, isbn)
+		if err != nil {
+			return err
+		}
+		if err := repo.updated(res, isbn); err != nil {
+			return err
+		}
+		if _, err := repo.conn().Exec("DELETE FROM book_author WHERE id_book = $1", isbn); err != nil {
+			return err
+		}
+		return repo.linkAuthors(isbn, authors)
+	})
 	return res, err
 }
 
+// updated fails with ErrBookNotFound when the UPDATE that returned res found
+// no book under isbn. MySQL only counts the rows an update changed, so when
+// none was the book is looked up before it is reported missing.
+func (repo BookRepository) updated(res sql.Result, isbn string) error {
+	affected, err := res.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+	var count int
+	if err := repo.conn().QueryRow("SELECT COUNT(*) FROM Book WHERE isbn = $1", isbn).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrBookNotFound
+	}
+	return nil
+}
+
 func (repo BookRepository) Delete(isbn string) (sql.Result, error) {
-	cmd := "DELETE FROM Book WHERE isbn = $1"
-	res, err := repo.DB.Exec(cmd, isbn)
+	var res sql.Result
+	err := repo.write(isbn, func(repo BookRepository) error {
+		if repo.softDeletes() {
+			var err error
+			cmd := "UPDATE Book SET deleted_at = $1, version = version + 1 WHERE isbn = $2 AND deleted_at IS NULL"
+			res, err = repo.conn().Exec(cmd, time.Now().UTC(), isbn)
+			return err
+		}
+		if repo.schema() == SchemaV3 {
+			if _, err := repo.conn().Exec("DELETE FROM book_author WHERE id_book = $1", isbn); err != nil {
+				return err
+			}
+		}
+		var err error
+		res, err = repo.conn().Exec("DELETE FROM Book WHERE isbn = $1", isbn)
+		return err
+	})
 	return res, err
 }
 
-func (repo BookRepository) Insert(isbn, name, author string, publish_year int) (sql.Result, error) {
-	cmd := "INSERT INTO Book (isbn, name, publish_year, author) VALUES ($1, $2, $3, $4)"
-	res, err := repo.DB.Exec(cmd, isbn, name, publish_year, author)
+func (repo BookRepository) Insert(isbn, name string, authors []string, publish_year int) (sql.Result, error) {
+	var res sql.Result
+	err := repo.write(isbn, func(repo BookRepository) error {
+		var err error
+		switch repo.schema() {
+		case SchemaV1:
+			author, err := singleAuthor(authors, false)
+			if err != nil {
+				return err
+			}
+			cmd := "INSERT INTO Book (isbn, name, publish_year, author) VALUES ($1, $2, $3, $4)"
+			res, err = repo.conn().Exec(cmd, isbn, name, publish_year, nullString(author))
+			return err
+		case SchemaV2:
+			author, err := singleAuthor(authors, true)
+			if err != nil {
+				return err
+			}
+			authorID, err := repo.authorID(author)
+			if err != nil {
+				return err
+			}
+			cmd := "INSERT INTO Book (isbn, name, publish_year, id_author) VALUES ($1, $2, $3, $4)"
+			res, err = repo.conn().Exec(cmd, isbn, name, publish_year, authorID)
+			return err
+		}
+		cmd := "INSERT INTO Book (isbn, name, publish_year) VALUES ($1, $2, $3)"
+		res, err = repo.conn().Exec(cmd, isbn, name, publish_year)
+		if err != nil {
+			return err
+		}
+		return repo.linkAuthors(isbn, authors)
+	})
+	if isUniqueViolation(err) {
+		return res, ErrBookExists
+	}
 	return res, err
 }
+
+// bookRow returns the Book columns of the schema, isbn first, and the values
+// to store in them.
+func (repo BookRepository) bookRow(isbn, name string, authors []string, publish_year int) ([]string, []any, error) {
+	columns := []string{"isbn", "name", "publish_year"}
+	values := []any{isbn, name, publish_year}
+	switch repo.schema() {
+	case SchemaV1:
+		author, err := singleAuthor(authors, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(columns, "author"), append(values, nullString(author)), nil
+	case SchemaV2:
+		author, err := singleAuthor(authors, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		authorID, err := repo.authorID(author)
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(columns, "id_author"), append(values, authorID), nil
+	}
+	return columns, values, nil
+}
+
+// Upsert inserts the book or, when its isbn is taken, overwrites it in the
+// same statement, and reports which of the two it did. A deleted book is
+// left alone with ErrBookDeleted.
+func (repo BookRepository) Upsert(isbn, name string, authors []string, publish_year int) (bool, error) {
+	var created bool
+	err := repo.write(isbn, func(repo BookRepository) error {
+		if repo.softDeletes() {
+			deleted, err := repo.tombstoned(isbn)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				return ErrBookDeleted
+			}
+		}
+		columns, values, err := repo.bookRow(isbn, name, authors, publish_year)
+		if err != nil {
+			return err
+		}
+		marks := []string{}
+		set := []string{}
+		for i, column := range columns {
+			marks = append(marks, "$"+strconv.Itoa(i+1))
+			if i == 0 {
+				continue
+			}
+			if repo.Dialect.Name == MySQL.Name {
+				set = append(set, column+" = VALUES("+column+")")
+			} else {
+				set = append(set, column+" = EXCLUDED."+column)
+			}
+		}
+		if repo.versioned() {
+			set = append(set, "version = Book.version + 1")
+		}
+		cmd := "INSERT INTO Book (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(marks, ", ") + ")"
+
+		switch {
+		case repo.Dialect.Name == MySQL.Name:
+			// MySQL counts an inserted row once and an updated one twice, or
+			// not at all when the update changed nothing.
+			res, err := repo.conn().Exec(cmd+" ON DUPLICATE KEY UPDATE "+strings.Join(set, ", "), values...)
+			if err != nil {
+				return err
+			}
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			created = affected == 1
+		case repo.Dialect.isPostgres():
+			// Only the row version left behind by an update has an xmax.
+			cmd += " ON CONFLICT (isbn) DO UPDATE SET " + strings.Join(set, ", ") + " RETURNING (xmax = 0)"
+			if err := repo.conn().QueryRow(cmd, values...).Scan(&created); err != nil {
+				return err
+			}
+		default:
+			// SQLite does not say which way an upsert went, so look first.
+			// A writer getting in between makes the upsert fail as busy
+			// rather than leave the answer stale.
+			var count int
+			if err := repo.conn().QueryRow("SELECT COUNT(*) FROM Book WHERE isbn = $1", isbn).Scan(&count); err != nil {
+				return err
+			}
+			created = count == 0
+			if _, err := repo.conn().Exec(cmd+" ON CONFLICT (isbn) DO UPDATE SET "+strings.Join(set, ", "), values...); err != nil {
+				return err
+			}
+		}
+
+		if repo.schema() != SchemaV3 {
+			return nil
+		}
+		if !created {
+			if _, err := repo.conn().Exec("DELETE FROM book_author WHERE id_book = $1", isbn); err != nil {
+				return err
+			}
+		}
+		return repo.linkAuthors(isbn, authors)
+	})
+	return created, err
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import "errors"
+
+// Kinds of failure. Every error returned by the stores and the services
+// matches one of them with errors.Is, and the HTTP layer picks the status
+// code from the kind alone. Errors of no kind are internal.
+var (
+	ErrNotFound   = errors.New("Not found")
+	ErrConflict   = errors.New("Conflict")
+	ErrInvalid    = errors.New("Invalid")
+	ErrBadRequest = errors.New("Bad request")
+	// ErrPrecondition is a write made against a version of a book that is
+	// no longer the stored one.
+	ErrPrecondition = errors.New("Precondition failed")
+	ErrInternal     = errors.New("Internal error")
+)
+
+// Error is a domain error: Kind is one of the kinds above and Err says what
+// went wrong. It matches both with errors.Is.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (err *Error) Error() string {
+	return err.Err.Error()
+}
+
+func (err *Error) Unwrap() []error {
+	return []error{err.Kind, err.Err}
+}
+
+// NewError returns a new error of kind.
+func NewError(kind error, message string) error {
+	return &Error{Kind: kind, Err: errors.New(message)}
+}
+
+// WithKind marks err as being of kind, keeping its message.
+func WithKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// ErrSomethingWentWrong is what clients are told of internal errors, whose
+// details stay in the log.
+var ErrSomethingWentWrong = NewError(ErrInternal, "Something went wrong")
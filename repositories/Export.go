@@ -0,0 +1,71 @@
+package repositories
+
+// EachBook calls fn with every book matching filter, in isbn order, as the
+// rows come in: only the book being read is held in memory, whatever the
+// size of the catalog. An error from fn stops the walk and is returned.
+//
+// The rows hold their connection until the walk ends, which on SQLite, with
+// its single connection, keeps every other query waiting.
+func (repo BookRepository) EachBook(filter BookFilter, fn func(Book) error) error {
+	where := repo.filter(filter)
+	// The rows of a book, one per author, have to come together for the
+	// book to be complete when the next one starts.
+	cmd := repo.queries().selectBooks + where.where() + ` ORDER BY b.isbn`
+	L.Info("Querying " + cmd)
+	row, err := repo.conn().Query(cmd, where.args...)
+	if err != nil {
+		L.Error("Error ", err)
+		return err
+	}
+	defer row.Close()
+	columns, err := row.Columns()
+	if err != nil {
+		return err
+	}
+
+	var current *Book
+	for row.Next() {
+		book, author, err := scanBook(row, len(columns))
+		if err != nil {
+			L.Error("Error ", err)
+			return err
+		}
+		if current == nil || current.ISBN != book.ISBN {
+			if current != nil {
+				if err := fn(*current); err != nil {
+					return err
+				}
+			}
+			current = &book
+		}
+		if author != "" {
+			current.Authors = append(current.Authors, author)
+		}
+	}
+	if err := row.Err(); err != nil {
+		L.Error("Error ", err)
+		return err
+	}
+	if current != nil {
+		return fn(*current)
+	}
+	return nil
+}
+
+// EachBook walks a copy of the matching books, so a slow fn does not keep
+// the writers waiting.
+func (repo MemoryBookRepository) EachBook(filter BookFilter, fn func(Book) error) error {
+	books, err := repo.list(func(data *memoryData, stored memoryBook) bool { return true })
+	if err == ErrNoBooks {
+		return nil
+	}
+	for _, book := range books {
+		if !filter.matches(book) {
+			continue
+		}
+		if err := fn(book); err != nil {
+			return err
+		}
+	}
+	return nil
+}
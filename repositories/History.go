@@ -0,0 +1,303 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// SchemaBookHistory is the migration that adds book_history.
+const SchemaBookHistory uint = 6
+
+// Actions recorded in the history.
+const (
+	ActionInsert = "insert"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	// ActionRestore takes the tombstone off a deleted book and ActionPurge
+	// removes a deleted book for good.
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// Anonymous is the actor recorded for writes made by nobody in particular.
+const Anonymous = "anonymous"
+
+// sortHistory is the only order of the history, oldest change first unless
+// the page asks for Desc.
+const sortHistory = "id"
+
+// Change is one write to a book as the history keeps it. Before is nil for
+// inserts and After for deletes.
+type Change struct {
+	ID        int64     `json:"id"`
+	ISBN      string    `json:"isbn"`
+	Action    string    `json:"action"`
+	Before    *Book     `json:"before"`
+	After     *Book     `json:"after"`
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// HistoryPage is one page of the history of a book. NextCursor is empty on
+// the last page.
+type HistoryPage struct {
+	Changes    []Change
+	NextCursor string
+}
+
+// newChange describes the write that turned before into after, and reports
+// false for writes that found no book and left none.
+func newChange(isbn, actor string, before, after *Book) (Change, bool) {
+	change := Change{ISBN: isbn, Before: before, After: after, Actor: actor, ChangedAt: time.Now().UTC()}
+	switch {
+	case before == nil && after == nil:
+		return change, false
+	case before == nil:
+		change.Action = ActionInsert
+	case after == nil && before.DeletedAt != nil:
+		change.Action = ActionPurge
+	case after == nil, before.DeletedAt == nil && after.DeletedAt != nil:
+		change.Action = ActionDelete
+	case before.DeletedAt != nil && after.DeletedAt == nil:
+		change.Action = ActionRestore
+	default:
+		change.Action = ActionUpdate
+	}
+	if change.Actor == "" {
+		change.Actor = Anonymous
+	}
+	return change, true
+}
+
+// normalizeHistory fills in the defaults of a history page and decodes its
+// cursor, the id of the last change of the previous page.
+func (page PageRequest) normalizeHistory() (PageRequest, int64, error) {
+	page.Sort = sortHistory
+	if page.Limit == 0 {
+		page.Limit = DefaultLimit
+	}
+	if page.Limit < 0 || page.Limit > MaxLimit {
+		return page, 0, ErrInvalidLimit
+	}
+	if page.Cursor == "" {
+		return page, 0, nil
+	}
+	after, err := page.decode()
+	if err != nil {
+		return page, 0, err
+	}
+	id, err := strconv.ParseInt(after.Value, 10, 64)
+	if err != nil {
+		return page, 0, ErrInvalidCursor
+	}
+	return page, id, nil
+}
+
+// nextChange returns the cursor that continues after change.
+func (page PageRequest) nextChange(change Change) string {
+	return cursor{Sort: sortHistory, Desc: page.Desc, Value: strconv.FormatInt(change.ID, 10)}.encode()
+}
+
+func (repo BookRepository) audited() bool {
+	return repo.Version >= SchemaBookHistory
+}
+
+// As returns the repository recording actor as the author of its writes.
+func (repo BookRepository) As(actor string) BookStore {
+	repo.actor = actor
+	return repo
+}
+
+// write runs fn in a transaction and records in book_history what it did
+// to isbn, as part of that transaction. Schemas without the table only run
+// fn.
+func (repo BookRepository) write(isbn string, fn func(repo BookRepository) error) error {
+	return repo.transaction(func(repo BookRepository) error {
+		if !repo.audited() {
+			return fn(repo)
+		}
+		return repo.audit(isbn, fn)
+	})
+}
+
+func (repo BookRepository) audit(isbn string, fn func(repo BookRepository) error) error {
+	before, err := repo.snapshot(isbn)
+	if err != nil {
+		return err
+	}
+	if err := fn(repo); err != nil {
+		return err
+	}
+	after, err := repo.snapshot(isbn)
+	if err != nil {
+		return err
+	}
+	change, changed := newChange(isbn, repo.actor, before, after)
+	if !changed {
+		return nil
+	}
+	return repo.record(change)
+}
+
+// snapshot returns the book stored under isbn, deleted or not, or nil when
+// there is none.
+func (repo BookRepository) snapshot(isbn string) (*Book, error) {
+	repo.withDeleted = true
+	book, err := repo.GetByISBN(isbn)
+	if err == ErrBookNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+func (repo BookRepository) record(change Change) error {
+	before, err := bookJSON(change.Before)
+	if err != nil {
+		return err
+	}
+	after, err := bookJSON(change.After)
+	if err != nil {
+		return err
+	}
+	cmd := `INSERT INTO book_history (isbn, action, old_book, new_book, actor, changed_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err = repo.conn().Exec(cmd, change.ISBN, change.Action, before, after, change.Actor, change.ChangedAt)
+	return err
+}
+
+func bookJSON(book *Book) (sql.NullString, error) {
+	if book == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(book)
+	return sql.NullString{String: string(data), Valid: true}, err
+}
+
+func parseBook(data sql.NullString) (*Book, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+	book := &Book{}
+	return book, json.Unmarshal([]byte(data.String), book)
+}
+
+// selectChanges reads book_history in the column order of scanChanges.
+const selectChanges = `SELECT id,isbn,action,old_book,new_book,actor,changed_at from book_history`
+
+func scanChanges(row *sql.Rows) ([]Change, error) {
+	changes := []Change{}
+	for row.Next() {
+		change := Change{}
+		var before, after sql.NullString
+		if err := row.Scan(&change.ID, &change.ISBN, &change.Action, &before, &after, &change.Actor, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		var err error
+		if change.Before, err = parseBook(before); err != nil {
+			return nil, err
+		}
+		if change.After, err = parseBook(after); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, row.Err()
+}
+
+// History returns one page of the changes made to the book stored under
+// isbn, deleted or not. Schemas without book_history have none.
+func (repo BookRepository) History(isbn string, page PageRequest) (HistoryPage, error) {
+	page, after, err := page.normalizeHistory()
+	if err != nil {
+		return HistoryPage{}, err
+	}
+	result := HistoryPage{Changes: []Change{}}
+	if !repo.audited() {
+		return result, nil
+	}
+	where := conditions{}
+	where.add(`isbn=%s`, isbn)
+	op, dir := ">", " ASC"
+	if page.Desc {
+		op, dir = "<", " DESC"
+	}
+	if after != 0 {
+		where.add(`id `+op+` %s`, after)
+	}
+	cmd := selectChanges + where.where() + ` ORDER BY id` + dir + ` LIMIT ` + strconv.Itoa(page.Limit+1)
+	L.Info("Querying " + cmd)
+	row, err := repo.conn().Query(cmd, where.args...)
+	if err != nil {
+		L.Error("Error ", err)
+		return HistoryPage{}, err
+	}
+	defer row.Close()
+	if result.Changes, err = scanChanges(row); err != nil {
+		L.Error("Error ", err)
+		return HistoryPage{}, err
+	}
+	if len(result.Changes) > page.Limit {
+		result.Changes = result.Changes[:page.Limit]
+		result.NextCursor = page.nextChange(result.Changes[page.Limit-1])
+	}
+	return result, nil
+}
+
+// audit runs write and appends to the history what it did to isbn. The
+// append is undone with the rest of the transaction.
+func (tx *memoryTx) audit(isbn, actor string, write func()) {
+	before := tx.data.snapshot(isbn)
+	write()
+	change, changed := newChange(isbn, actor, before, tx.data.snapshot(isbn))
+	if !changed {
+		return
+	}
+	length := len(tx.data.history)
+	tx.undo = append(tx.undo, func() { tx.data.history = tx.data.history[:length] })
+	change.ID = int64(length + 1)
+	tx.data.history = append(tx.data.history, change)
+}
+
+func (data *memoryData) snapshot(isbn string) *Book {
+	stored, ok := data.books[isbn]
+	if !ok {
+		return nil
+	}
+	book := data.book(isbn, stored)
+	return &book
+}
+
+func (repo MemoryBookRepository) As(actor string) BookStore {
+	repo.actor = actor
+	return repo
+}
+
+func (repo MemoryBookRepository) History(isbn string, page PageRequest) (HistoryPage, error) {
+	page, after, err := page.normalizeHistory()
+	if err != nil {
+		return HistoryPage{}, err
+	}
+	result := HistoryPage{Changes: []Change{}}
+	repo.db.view(repo.tx, func(data *memoryData) error {
+		for i := range data.history {
+			change := data.history[i]
+			if page.Desc {
+				change = data.history[len(data.history)-1-i]
+			}
+			if change.ISBN != isbn || (after != 0 && !page.Desc && change.ID <= after) || (after != 0 && page.Desc && change.ID >= after) {
+				continue
+			}
+			if len(result.Changes) == page.Limit {
+				result.NextCursor = page.nextChange(result.Changes[page.Limit-1])
+				break
+			}
+			result.Changes = append(result.Changes, change)
+		}
+		return nil
+	})
+	return result, nil
+}
@@ -0,0 +1,441 @@
+package repositories
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryBook struct {
+	name        string
+	publishYear int
+	authorIDs   []int
+	version     int
+	deletedAt   *time.Time
+}
+
+type memoryData struct {
+	books        map[string]memoryBook
+	authors      map[int]Author
+	nextAuthorID int
+	history      []Change
+}
+
+// MemoryDB is the state shared by MemoryBookRepository and
+// MemoryAuthorRepository. Reads share a lock, writes and transactions take
+// it exclusively, so it is safe to use from concurrent handlers.
+type MemoryDB struct {
+	mu    sync.RWMutex
+	data  memoryData
+	index searchCache
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		data: memoryData{
+			books:        map[string]memoryBook{},
+			authors:      map[int]Author{},
+			nextAuthorID: 1,
+		},
+	}
+}
+
+// memoryTx is a write in progress. Every change records how to undo itself,
+// so a failed transaction can be rolled back without copying the data.
+type memoryTx struct {
+	data *memoryData
+	undo []func()
+}
+
+// setBook stores book under isbn, one version after the book it replaces.
+func (tx *memoryTx) setBook(isbn string, book memoryBook) {
+	old, existed := tx.data.books[isbn]
+	book.version = old.version + 1
+	tx.undo = append(tx.undo, func() {
+		if existed {
+			tx.data.books[isbn] = old
+		} else {
+			delete(tx.data.books, isbn)
+		}
+	})
+	tx.data.books[isbn] = book
+}
+
+func (tx *memoryTx) deleteBook(isbn string) {
+	old, existed := tx.data.books[isbn]
+	if !existed {
+		return
+	}
+	tx.undo = append(tx.undo, func() { tx.data.books[isbn] = old })
+	delete(tx.data.books, isbn)
+}
+
+func (tx *memoryTx) setAuthor(author Author) {
+	old, existed := tx.data.authors[author.ID]
+	tx.undo = append(tx.undo, func() {
+		if existed {
+			tx.data.authors[author.ID] = old
+		} else {
+			delete(tx.data.authors, author.ID)
+		}
+	})
+	tx.data.authors[author.ID] = author
+}
+
+func (tx *memoryTx) deleteAuthor(id int) {
+	old, existed := tx.data.authors[id]
+	if !existed {
+		return
+	}
+	tx.undo = append(tx.undo, func() { tx.data.authors[id] = old })
+	delete(tx.data.authors, id)
+}
+
+func (tx *memoryTx) insertAuthor(author Author) Author {
+	next := tx.data.nextAuthorID
+	tx.undo = append(tx.undo, func() { tx.data.nextAuthorID = next })
+	author.ID = next
+	tx.data.nextAuthorID++
+	tx.setAuthor(author)
+	return author
+}
+
+func (tx *memoryTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+// view runs fn with read access, inside tx when there is one.
+func (db *MemoryDB) view(tx *memoryTx, fn func(data *memoryData) error) error {
+	if tx != nil {
+		return fn(tx.data)
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return fn(&db.data)
+}
+
+// update runs fn with write access. Outside a transaction fn gets one of its
+// own, so a single call is all-or-nothing too.
+func (db *MemoryDB) update(tx *memoryTx, fn func(tx *memoryTx) error) error {
+	if tx != nil {
+		return fn(tx)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	defer db.index.invalidate()
+	tx = &memoryTx{data: &db.data}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	return nil
+}
+
+func (data *memoryData) book(isbn string, stored memoryBook) Book {
+	book := Book{
+		ISBN:        isbn,
+		Name:        stored.name,
+		PublishYear: stored.publishYear,
+		Authors:     []string{},
+		Version:     stored.version,
+		DeletedAt:   stored.deletedAt,
+	}
+	for _, id := range stored.authorIDs {
+		book.Authors = append(book.Authors, data.authors[id].Name)
+	}
+	return book
+}
+
+// filter returns the books matching keep, ordered by isbn.
+func (data *memoryData) filter(keep func(data *memoryData, stored memoryBook) bool) []Book {
+	isbns := []string{}
+	for isbn, stored := range data.books {
+		if keep(data, stored) {
+			isbns = append(isbns, isbn)
+		}
+	}
+	sort.Strings(isbns)
+	books := []Book{}
+	for _, isbn := range isbns {
+		books = append(books, data.book(isbn, data.books[isbn]))
+	}
+	return books
+}
+
+func (data *memoryData) authorByName(name string) (Author, bool) {
+	found := Author{}
+	for _, author := range data.authors {
+		if author.Name == name && (found.ID == 0 || author.ID < found.ID) {
+			found = author
+		}
+	}
+	return found, found.ID != 0
+}
+
+// authorIDs resolves names to author ids, creating missing authors.
+func (tx *memoryTx) authorIDs(names []string) []int {
+	ids := []int{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		author, ok := tx.data.authorByName(name)
+		if !ok {
+			author = tx.insertAuthor(Author{Name: name})
+		}
+		ids = append(ids, author.ID)
+	}
+	return ids
+}
+
+type MemoryBookRepository struct {
+	db *MemoryDB
+	tx *memoryTx
+	// actor is recorded in the history as the author of the writes.
+	actor string
+	// withDeleted makes the reads include deleted books.
+	withDeleted bool
+}
+
+func NewMemoryBookRepository(db *MemoryDB) *MemoryBookRepository {
+	return &MemoryBookRepository{db: db}
+}
+
+func (repo MemoryBookRepository) Transaction(fn func(store BookStore) error) error {
+	return repo.db.update(repo.tx, func(tx *memoryTx) error {
+		return fn(MemoryBookRepository{db: repo.db, tx: tx, actor: repo.actor, withDeleted: repo.withDeleted})
+	})
+}
+
+func (repo MemoryBookRepository) list(keep func(data *memoryData, stored memoryBook) bool) ([]Book, error) {
+	var books []Book
+	repo.db.view(repo.tx, func(data *memoryData) error {
+		books = data.filter(func(data *memoryData, stored memoryBook) bool {
+			return repo.visible(stored) && keep(data, stored)
+		})
+		return nil
+	})
+	if len(books) == 0 {
+		return nil, ErrNoBooks
+	}
+	return books, nil
+}
+
+func (repo MemoryBookRepository) GetAllBooks() ([]Book, error) {
+	return repo.list(func(data *memoryData, stored memoryBook) bool { return true })
+}
+
+func (repo MemoryBookRepository) GetByISBN(isbn string) (Book, error) {
+	book := Book{}
+	err := repo.db.view(repo.tx, func(data *memoryData) error {
+		stored, ok := data.books[isbn]
+		if !ok || !repo.visible(stored) {
+			return ErrBookNotFound
+		}
+		book = data.book(isbn, stored)
+		return nil
+	})
+	return book, err
+}
+
+func (repo MemoryBookRepository) GetByAuthor(author string) ([]Book, error) {
+	return repo.list(func(data *memoryData, stored memoryBook) bool {
+		for _, id := range stored.authorIDs {
+			if data.authors[id].Name == author {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (repo MemoryBookRepository) GetInRange(year1, year2 int) ([]Book, error) {
+	return repo.list(func(data *memoryData, stored memoryBook) bool {
+		return stored.publishYear >= year1 && stored.publishYear <= year2
+	})
+}
+
+func (repo MemoryBookRepository) ListBooks(filter BookFilter, page PageRequest) (BookPage, error) {
+	page, after, err := page.normalize()
+	if err != nil {
+		return BookPage{}, err
+	}
+	books, err := repo.GetAllBooks()
+	if err != nil {
+		return BookPage{}, err
+	}
+	matching := []Book{}
+	for _, book := range books {
+		if filter.matches(book) {
+			matching = append(matching, book)
+		}
+	}
+	result := paginate(matching, page, after)
+	if len(result.Books) == 0 {
+		return BookPage{}, ErrNoBooks
+	}
+	return result, nil
+}
+
+func (repo MemoryBookRepository) Insert(isbn, name string, authors []string, publish_year int) (sql.Result, error) {
+	err := repo.db.update(repo.tx, func(tx *memoryTx) error {
+		if _, exists := tx.data.books[isbn]; exists {
+			return ErrBookExists
+		}
+		tx.audit(isbn, repo.actor, func() {
+			tx.setBook(isbn, memoryBook{name: name, publishYear: publish_year, authorIDs: tx.authorIDs(authors)})
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (repo MemoryBookRepository) Update(isbn, name string, authors []string, publish_year int) (sql.Result, error) {
+	var affected driver.RowsAffected
+	err := repo.db.update(repo.tx, func(tx *memoryTx) error {
+		if stored, exists := tx.data.books[isbn]; !exists || !repo.visible(stored) {
+			return ErrBookNotFound
+		}
+		tx.audit(isbn, repo.actor, func() {
+			tx.setBook(isbn, memoryBook{name: name, publishYear: publish_year, authorIDs: tx.authorIDs(authors)})
+		})
+		affected = 1
+		return nil
+	})
+	return affected, err
+}
+
+func (repo MemoryBookRepository) Upsert(isbn, name string, authors []string, publish_year int) (bool, error) {
+	var created bool
+	err := repo.db.update(repo.tx, func(tx *memoryTx) error {
+		stored, exists := tx.data.books[isbn]
+		if exists && stored.deletedAt != nil {
+			return ErrBookDeleted
+		}
+		created = !exists
+		tx.audit(isbn, repo.actor, func() {
+			tx.setBook(isbn, memoryBook{name: name, publishYear: publish_year, authorIDs: tx.authorIDs(authors)})
+		})
+		return nil
+	})
+	return created, err
+}
+
+func (repo MemoryBookRepository) Delete(isbn string) (sql.Result, error) {
+	var affected driver.RowsAffected
+	err := repo.db.update(repo.tx, func(tx *memoryTx) error {
+		if stored, exists := tx.data.books[isbn]; exists && stored.deletedAt == nil {
+			tx.audit(isbn, repo.actor, func() {
+				now := time.Now().UTC()
+				stored.deletedAt = &now
+				tx.setBook(isbn, stored)
+			})
+			affected = 1
+		}
+		return nil
+	})
+	return affected, err
+}
+
+type MemoryAuthorRepository struct {
+	db *MemoryDB
+	// tx is the transaction the repository is bound to, if any.
+	tx *memoryTx
+}
+
+func NewMemoryAuthorRepository(db *MemoryDB) *MemoryAuthorRepository {
+	return &MemoryAuthorRepository{db: db}
+}
+
+func (repo MemoryAuthorRepository) GetAll() ([]Author, error) {
+	authors := []Author{}
+	repo.db.view(repo.tx, func(data *memoryData) error {
+		for _, author := range data.authors {
+			authors = append(authors, author)
+		}
+		return nil
+	})
+	sort.Slice(authors, func(i, j int) bool { return authors[i].ID < authors[j].ID })
+	return authors, nil
+}
+
+func (repo MemoryAuthorRepository) GetByID(id int) (Author, error) {
+	author := Author{}
+	err := repo.db.view(repo.tx, func(data *memoryData) error {
+		stored, ok := data.authors[id]
+		if !ok {
+			return ErrAuthorNotFound
+		}
+		author = stored
+		return nil
+	})
+	return author, err
+}
+
+func (repo MemoryAuthorRepository) GetByName(name string) (Author, error) {
+	author := Author{}
+	err := repo.db.view(repo.tx, func(data *memoryData) error {
+		stored, ok := data.authorByName(name)
+		if !ok {
+			return ErrAuthorNotFound
+		}
+		author = stored
+		return nil
+	})
+	return author, err
+}
+
+func (repo MemoryAuthorRepository) Insert(author Author) (Author, error) {
+	err := repo.db.update(repo.tx, func(tx *memoryTx) error {
+		author = tx.insertAuthor(author)
+		return nil
+	})
+	return author, err
+}
+
+func (repo MemoryAuthorRepository) Update(author Author) (sql.Result, error) {
+	var affected driver.RowsAffected
+	err := repo.db.update(repo.tx, func(tx *memoryTx) error {
+		if _, exists := tx.data.authors[author.ID]; exists {
+			tx.setAuthor(author)
+			affected = 1
+		}
+		return nil
+	})
+	return affected, err
+}
+
+func (repo MemoryAuthorRepository) Delete(id int) (sql.Result, error) {
+	var affected driver.RowsAffected
+	err := repo.db.update(repo.tx, func(tx *memoryTx) error {
+		for _, stored := range tx.data.books {
+			for _, authorID := range stored.authorIDs {
+				if authorID == id {
+					return ErrAuthorHasBooks
+				}
+			}
+		}
+		if _, exists := tx.data.authors[id]; exists {
+			tx.deleteAuthor(id)
+			affected = 1
+		}
+		return nil
+	})
+	return affected, err
+}
+
+func (repo MemoryAuthorRepository) Transaction(fn func(store AuthorStore) error) error {
+	return repo.db.update(repo.tx, func(tx *memoryTx) error {
+		return fn(MemoryAuthorRepository{db: repo.db, tx: tx})
+	})
+}
@@ -0,0 +1,301 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Orders accepted by ListBooks. Ties are always broken by isbn, so every
+// order is total and a cursor points at exactly one place in it.
+const (
+	SortISBN        = "isbn"
+	SortName        = "name"
+	SortPublishYear = "publish_year"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+var (
+	ErrInvalidSort   = NewError(ErrBadRequest, "Sort must be one of isbn, name or publish_year")
+	ErrInvalidLimit  = NewError(ErrBadRequest, "Limit must be between 1 and 500")
+	ErrInvalidCursor = NewError(ErrBadRequest, "Cursor does not belong to this listing")
+)
+
+var sortColumns = map[string]string{
+	SortISBN:        "b.isbn",
+	SortName:        "b.name",
+	SortPublishYear: "b.publish_year",
+}
+
+// BookFilter narrows a listing down. Every field that is set must match,
+// zero fields do not filter.
+type BookFilter struct {
+	ISBN string
+	// ISBNPrefix keeps books whose isbn starts with it.
+	ISBNPrefix string
+	Author     string
+	// Title keeps books whose name contains it, ignoring case.
+	Title string
+	// FromYear and ToYear bound publish_year, both ends included.
+	FromYear *int
+	ToYear   *int
+}
+
+func (filter BookFilter) matches(book Book) bool {
+	if filter.ISBN != "" && book.ISBN != filter.ISBN {
+		return false
+	}
+	if !strings.HasPrefix(book.ISBN, filter.ISBNPrefix) {
+		return false
+	}
+	if !strings.Contains(strings.ToLower(book.Name), strings.ToLower(filter.Title)) {
+		return false
+	}
+	if filter.FromYear != nil && book.PublishYear < *filter.FromYear {
+		return false
+	}
+	if filter.ToYear != nil && book.PublishYear > *filter.ToYear {
+		return false
+	}
+	if filter.Author != "" {
+		for _, author := range book.Authors {
+			if author == filter.Author {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// PageRequest asks for one page of a listing. Cursor is the NextCursor of
+// the previous page and only works with the Sort and Desc it was made for.
+type PageRequest struct {
+	Limit  int
+	Sort   string
+	Desc   bool
+	Cursor string
+}
+
+// BookPage is one page of a listing. NextCursor is empty on the last page
+// and Total counts every matching book, not just the ones on this page.
+type BookPage struct {
+	Books      []Book
+	NextCursor string
+	Total      int
+}
+
+// cursor is the position after the last book of a page, kept by sort key
+// rather than offset so pages stay stable while books are added.
+type cursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ISBN  string `json:"i"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// value returns the sort key with the type of its column.
+func (c cursor) value() any {
+	if c.Sort == SortPublishYear {
+		year, _ := strconv.Atoi(c.Value)
+		return year
+	}
+	return c.Value
+}
+
+func sortKey(book Book, order string) string {
+	switch order {
+	case SortName:
+		return book.Name
+	case SortPublishYear:
+		return strconv.Itoa(book.PublishYear)
+	}
+	return book.ISBN
+}
+
+// normalize fills in the defaults and decodes the cursor, if any.
+func (page PageRequest) normalize() (PageRequest, *cursor, error) {
+	if page.Limit == 0 {
+		page.Limit = DefaultLimit
+	}
+	if page.Limit < 0 || page.Limit > MaxLimit {
+		return page, nil, ErrInvalidLimit
+	}
+	if page.Sort == "" {
+		page.Sort = SortISBN
+	}
+	if _, ok := sortColumns[page.Sort]; !ok {
+		return page, nil, ErrInvalidSort
+	}
+	if page.Cursor == "" {
+		return page, nil, nil
+	}
+	after, err := page.decode()
+	if err != nil {
+		return page, nil, err
+	}
+	if _, err := strconv.Atoi(after.Value); err != nil && after.Sort == SortPublishYear {
+		return page, nil, ErrInvalidCursor
+	}
+	return page, after, nil
+}
+
+// decode reads the cursor of the page, which has to have been made for the
+// same order.
+func (page PageRequest) decode() (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(page.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	after := &cursor{}
+	if err := json.Unmarshal(data, after); err != nil || after.Sort != page.Sort || after.Desc != page.Desc {
+		return nil, ErrInvalidCursor
+	}
+	return after, nil
+}
+
+// next returns the cursor that continues after book.
+func (page PageRequest) next(book Book) string {
+	return cursor{Sort: page.Sort, Desc: page.Desc, Value: sortKey(book, page.Sort), ISBN: book.ISBN}.encode()
+}
+
+// less orders two books the way the page asks for.
+func (page PageRequest) less(a, b Book) bool {
+	var cmp int
+	switch page.Sort {
+	case SortName:
+		cmp = strings.Compare(a.Name, b.Name)
+	case SortPublishYear:
+		cmp = a.PublishYear - b.PublishYear
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.ISBN, b.ISBN)
+	}
+	if page.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// paginate cuts one page out of books that are already filtered, for
+// stores that cannot do it in a query.
+func paginate(books []Book, page PageRequest, after *cursor) BookPage {
+	sort.Slice(books, func(i, j int) bool { return page.less(books[i], books[j]) })
+	result := BookPage{Books: []Book{}, Total: len(books)}
+	start := 0
+	if after != nil {
+		last := Book{ISBN: after.ISBN, Name: after.Value}
+		last.PublishYear, _ = strconv.Atoi(after.Value)
+		start = sort.Search(len(books), func(i int) bool { return page.less(last, books[i]) })
+	}
+	end := start + page.Limit
+	if end < len(books) {
+		result.NextCursor = page.next(books[end-1])
+	} else {
+		end = len(books)
+	}
+	result.Books = append(result.Books, books[start:end]...)
+	return result
+}
+
+// conditions collects the where clauses of a dynamically built query.
+// Clauses are written with %s where their arguments go and get numbered
+// "$n" placeholders in the order they are added.
+type conditions struct {
+	clauses []string
+	args    []any
+}
+
+func (c *conditions) add(clause string, args ...any) {
+	marks := []any{}
+	for _, arg := range args {
+		marks = append(marks, c.arg(arg))
+	}
+	c.clauses = append(c.clauses, fmt.Sprintf(clause, marks...))
+}
+
+// arg adds an argument for a part of the query other than the clauses and
+// returns its placeholder.
+func (c *conditions) arg(value any) string {
+	c.args = append(c.args, value)
+	return "$" + strconv.Itoa(len(c.args))
+}
+
+func (c conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return ` where ` + strings.Join(c.clauses, " and ")
+}
+
+// likeEscape is the escape character of the LIKE patterns built here. It is
+// not a backslash because MySQL would read that as a string escape.
+const likeEscape = "!"
+
+var likeEscaper = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// filter turns a BookFilter into where clauses. Values always travel as
+// arguments, only the clauses themselves are pasted into the query.
+func (repo BookRepository) filter(filter BookFilter) conditions {
+	c := conditions{}
+	if live := repo.live(); live != "" {
+		c.add(live)
+	}
+	if filter.ISBN != "" {
+		c.add(`b.isbn=%s`, filter.ISBN)
+	}
+	if filter.ISBNPrefix != "" {
+		c.add(`b.isbn LIKE %s ESCAPE '`+likeEscape+`'`, likeEscaper.Replace(filter.ISBNPrefix)+"%")
+	}
+	if filter.Author != "" {
+		c.add(repo.queries().authorFilter, filter.Author)
+	}
+	if filter.Title != "" {
+		c.add(`LOWER(b.name) LIKE %s ESCAPE '`+likeEscape+`'`, "%"+likeEscaper.Replace(strings.ToLower(filter.Title))+"%")
+	}
+	if filter.FromYear != nil {
+		c.add(`b.publish_year>=%s`, *filter.FromYear)
+	}
+	if filter.ToYear != nil {
+		c.add(`b.publish_year<=%s`, *filter.ToYear)
+	}
+	return c
+}
+
+// keyset adds the condition that skips everything up to the cursor.
+func (c *conditions) keyset(page PageRequest, after *cursor) {
+	op := ">"
+	if page.Desc {
+		op = "<"
+	}
+	if page.Sort == SortISBN {
+		c.add(`b.isbn `+op+` %s`, after.ISBN)
+		return
+	}
+	column := sortColumns[page.Sort]
+	c.add(`(`+column+` `+op+` %s or (`+column+` = %s and b.isbn `+op+` %s))`, after.value(), after.value(), after.ISBN)
+}
+
+func (page PageRequest) orderBy() string {
+	dir := " ASC"
+	if page.Desc {
+		dir = " DESC"
+	}
+	column := sortColumns[page.Sort]
+	if page.Sort == SortISBN {
+		return ` ORDER BY ` + column + dir
+	}
+	return ` ORDER BY ` + column + dir + `, b.isbn` + dir
+}
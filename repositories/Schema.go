@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"database/sql"
+	"server/db/migration"
+)
+
+// The Book table has three layouts, one per migration:
+//
+//	v1: Book(isbn, name, publish_year, author)
+//	v2: Book(isbn, name, publish_year, id_author) -> Author(id, name, birth_date)
+//	v3: Book(isbn, name, publish_year) <- book_author(id_book, id_author) -> Author
+const (
+	SchemaV1 uint = 1
+	SchemaV2 uint = 2
+	SchemaV3 uint = 3
+)
+
+var (
+	ErrSingleAuthor = NewError(ErrInvalid, "This schema version stores exactly one author per book")
+	ErrNoAuthors    = NewError(ErrNotFound, "Authors are not available before schema version 2")
+)
+
+// querySet holds the SQL that differs between schema versions. Every select
+// returns isbn, name, publish_year and one author name per row, so scanBooks
+// works the same on all of them. fromBooks yields a single row per book and
+// is what listings count and paginate over; authorFilter works with both.
+type querySet struct {
+	selectBooks  string
+	fromBooks    string
+	authorFilter string
+}
+
+var querySets = map[uint]querySet{
+	SchemaV1: {
+		selectBooks:  `SELECT b.isbn,b.name,b.publish_year,b.author from Book b`,
+		fromBooks:    `from Book b`,
+		authorFilter: `b.author=%s`,
+	},
+	SchemaV2: {
+		selectBooks:  `SELECT b.isbn,b.name,b.publish_year,a.name from Book b LEFT JOIN Author a ON a.id = b.id_author`,
+		fromBooks:    `from Book b LEFT JOIN Author a ON a.id = b.id_author`,
+		authorFilter: `a.name=%s`,
+	},
+	SchemaV3: {
+		selectBooks:  `SELECT b.isbn,b.name,b.publish_year,a.name from Book b LEFT JOIN book_author ba ON ba.id_book = b.isbn LEFT JOIN Author a ON a.id = ba.id_author`,
+		fromBooks:    `from Book b`,
+		authorFilter: `b.isbn IN (SELECT ba2.id_book from book_author ba2 JOIN Author a2 ON a2.id = ba2.id_author where a2.name=%s)`,
+	},
+	SchemaSoftDelete: {
+		selectBooks:  `SELECT b.isbn,b.name,b.publish_year,a.name,b.version,b.deleted_at from Book b LEFT JOIN book_author ba ON ba.id_book = b.isbn LEFT JOIN Author a ON a.id = ba.id_author`,
+		fromBooks:    `from Book b`,
+		authorFilter: `b.isbn IN (SELECT ba2.id_book from book_author ba2 JOIN Author a2 ON a2.id = ba2.id_author where a2.name=%s)`,
+	},
+	SchemaBookVersion: {
+		selectBooks:  `SELECT b.isbn,b.name,b.publish_year,a.name,b.version from Book b LEFT JOIN book_author ba ON ba.id_book = b.isbn LEFT JOIN Author a ON a.id = ba.id_author`,
+		fromBooks:    `from Book b`,
+		authorFilter: `b.isbn IN (SELECT ba2.id_book from book_author ba2 JOIN Author a2 ON a2.id = ba2.id_author where a2.name=%s)`,
+	},
+}
+
+// DetectVersion reads the applied migration version from the database.
+func DetectVersion(db *sql.DB) (uint, error) {
+	version, dirty, err := migration.Version(db)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return version, migration.ErrDirty
+	}
+	return version, nil
+}
+
+// schemaOf maps an applied migration version onto the Book layout it
+// produced. Version 0 (unknown) and anything past v3 use the v3 layout.
+func schemaOf(version uint) uint {
+	switch version {
+	case SchemaV1, SchemaV2:
+		return version
+	default:
+		return SchemaV3
+	}
+}
+
+func (repo BookRepository) schema() uint {
+	return schemaOf(repo.Version)
+}
+
+func (repo BookRepository) queries() querySet {
+	if repo.softDeletes() {
+		return querySets[SchemaSoftDelete]
+	}
+	if repo.versioned() {
+		return querySets[SchemaBookVersion]
+	}
+	return querySets[repo.schema()]
+}
+
+// singleAuthor returns the author stored in the one-author layouts of v1/v2.
+func singleAuthor(authors []string, required bool) (string, error) {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, author := range authors {
+		if !seen[author] {
+			seen[author] = true
+			unique = append(unique, author)
+		}
+	}
+	if len(unique) > 1 || (required && len(unique) == 0) {
+		return "", ErrSingleAuthor
+	}
+	if len(unique) == 0 {
+		return "", nil
+	}
+	return unique[0], nil
+}
@@ -0,0 +1,377 @@
+package repositories
+
+import (
+	"server/search"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SchemaSearch is the migration that adds the Postgres full-text indexes.
+// Older Postgres schemas and the other databases search in the server.
+const SchemaSearch uint = 4
+
+var ErrEmptyQuery = NewError(ErrBadRequest, "Search query must contain a word")
+
+// Kinds of suggestions.
+const (
+	SuggestTitle  = "title"
+	SuggestAuthor = "author"
+)
+
+const DefaultSuggestions = 10
+
+// Suggestion is a title or author name completing what a user typed.
+// Books is how many books have that title or author.
+type Suggestion struct {
+	Text  string `json:"text"`
+	Kind  string `json:"kind"`
+	Books int    `json:"books"`
+}
+
+// SearchHit is a book found by Search. Highlight repeats the name and the
+// authors with the matching words wrapped in <mark> tags.
+type SearchHit struct {
+	Book      Book      `json:"book"`
+	Score     float64   `json:"score"`
+	Highlight Highlight `json:"highlight"`
+}
+
+type Highlight struct {
+	Name    string   `json:"name"`
+	Authors []string `json:"authors"`
+}
+
+func newSearchHit(book Book, score float64, query string) SearchHit {
+	hit := SearchHit{
+		Book:      book,
+		Score:     score,
+		Highlight: Highlight{Name: search.Highlight(book.Name, query), Authors: []string{}},
+	}
+	for _, author := range book.Authors {
+		hit.Highlight.Authors = append(hit.Highlight.Authors, search.Highlight(author, query))
+	}
+	return hit
+}
+
+func searchLimit(limit int) (int, error) {
+	if limit == 0 {
+		return DefaultLimit, nil
+	}
+	if limit < 0 || limit > MaxLimit {
+		return 0, ErrInvalidLimit
+	}
+	return limit, nil
+}
+
+// SearchCacheTTL is how long the search index of a SQL database is used
+// before it is rebuilt, however few writes this process made. It bounds how
+// long the writes of other processes go unseen.
+const SearchCacheTTL = 5 * time.Minute
+
+// SuggestRebuildInterval is the least time between two rebuilds of the
+// index started by suggestions, however many writes come in between.
+const SuggestRebuildInterval = time.Second
+
+// searchCache keeps an in-process index of every book. Writes to books and
+// authors bump the generation and the next search rebuilds the index from
+// every book, so it is meant for a single server whose catalog mostly gets
+// read. Suggestions, which come with every keystroke, do not wait for that:
+// they use the index as it is and rebuild it in the background. Writes made
+// by other processes to the same database are only seen once this process
+// writes too, or the index is older than ttl when there is one.
+type searchCache struct {
+	generation atomic.Int64
+	ttl        time.Duration
+
+	mu       sync.Mutex
+	built    int64
+	builtAt  time.Time
+	snapshot *searchSnapshot
+	// rebuilding is set while a background rebuild runs, and started is
+	// when the last one began.
+	rebuilding bool
+	started    time.Time
+}
+
+// searchSnapshot is the index built from the books at one generation. It is
+// never changed, so searches can use it without holding the cache lock.
+type searchSnapshot struct {
+	index     *search.Index
+	suggester *search.Suggester
+	books     map[string]Book
+}
+
+func (cache *searchCache) invalidate() {
+	if cache != nil {
+		cache.generation.Add(1)
+	}
+}
+
+// current reports whether the snapshot is of the current generation and
+// not expired. The caller holds the lock.
+func (cache *searchCache) current() bool {
+	expired := cache.ttl > 0 && time.Since(cache.builtAt) > cache.ttl
+	return cache.snapshot != nil && cache.built == cache.generation.Load() && !expired
+}
+
+// get returns the snapshot for the current generation, building it from
+// load if needed. A nil cache builds a fresh one on every call.
+func (cache *searchCache) get(load func() ([]Book, error)) (*searchSnapshot, error) {
+	if cache == nil {
+		cache = &searchCache{}
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.current() {
+		return cache.snapshot, nil
+	}
+	generation := cache.generation.Load()
+	books, err := load()
+	if err != nil && err != ErrNoBooks {
+		return nil, err
+	}
+	cache.snapshot = newSearchSnapshot(books)
+	cache.built = generation
+	cache.builtAt = time.Now()
+	return cache.snapshot, nil
+}
+
+// latest returns the snapshot there is, even one that writes made stale,
+// and has a stale one rebuilt in the background, no more often than
+// SuggestRebuildInterval. Only a cache without a snapshot yet, or a nil one,
+// builds it before returning.
+func (cache *searchCache) latest(load func() ([]Book, error)) (*searchSnapshot, error) {
+	if cache == nil {
+		return cache.get(load)
+	}
+	cache.mu.Lock()
+	snapshot := cache.snapshot
+	if snapshot == nil {
+		cache.mu.Unlock()
+		return cache.get(load)
+	}
+	if !cache.current() && !cache.rebuilding && time.Since(cache.started) >= SuggestRebuildInterval {
+		cache.rebuilding = true
+		cache.started = time.Now()
+		go cache.rebuild(load)
+	}
+	cache.mu.Unlock()
+	return snapshot, nil
+}
+
+// rebuild loads the books without holding the lock, so searches and
+// suggestions go on with the old snapshot meanwhile, and keeps the new
+// snapshot unless a search built a newer one first.
+func (cache *searchCache) rebuild(load func() ([]Book, error)) {
+	generation := cache.generation.Load()
+	books, err := load()
+	var snapshot *searchSnapshot
+	if err == nil || err == ErrNoBooks {
+		snapshot = newSearchSnapshot(books)
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.rebuilding = false
+	if snapshot == nil {
+		L.Error("Error rebuilding the search index ", err)
+		return
+	}
+	if generation >= cache.built {
+		cache.snapshot = snapshot
+		cache.built = generation
+		cache.builtAt = time.Now()
+	}
+}
+
+func newSearchSnapshot(books []Book) *searchSnapshot {
+	snapshot := &searchSnapshot{books: map[string]Book{}}
+	docs := []search.Document{}
+	titles := map[string]int{}
+	authors := map[string]int{}
+	for _, book := range books {
+		docs = append(docs, search.Document{ID: book.ISBN, Title: book.Name, Authors: book.Authors})
+		snapshot.books[book.ISBN] = book
+		titles[book.Name]++
+		for _, author := range book.Authors {
+			authors[author]++
+		}
+	}
+	entries := []search.Entry{}
+	for title, count := range titles {
+		entries = append(entries, search.Entry{Text: title, Kind: SuggestTitle, Weight: count})
+	}
+	for author, count := range authors {
+		entries = append(entries, search.Entry{Text: author, Kind: SuggestAuthor, Weight: count})
+	}
+	snapshot.index = search.NewIndex(docs)
+	snapshot.suggester = search.NewSuggester(entries)
+	return snapshot
+}
+
+func (snapshot *searchSnapshot) search(query string, limit int) []SearchHit {
+	hits := []SearchHit{}
+	for _, hit := range snapshot.index.Search(query, limit) {
+		hits = append(hits, newSearchHit(snapshot.books[hit.ID], hit.Score, query))
+	}
+	return hits
+}
+
+func (snapshot *searchSnapshot) suggest(prefix string, limit int) []Suggestion {
+	suggestions := []Suggestion{}
+	for _, suggestion := range snapshot.suggester.Suggest(prefix, limit) {
+		suggestions = append(suggestions, Suggestion{Text: suggestion.Text, Kind: suggestion.Kind, Books: suggestion.Weight})
+	}
+	return suggestions
+}
+
+// cache returns the shared index, or nil inside a transaction: rows it
+// wrote may still be rolled back and must not end up in the shared index.
+func (repo BookRepository) cache() *searchCache {
+	if repo.tx != nil || repo.withDeleted {
+		return nil
+	}
+	return repo.index
+}
+
+func (repo MemoryBookRepository) cache() *searchCache {
+	if repo.tx != nil || repo.withDeleted {
+		return nil
+	}
+	return &repo.db.index
+}
+
+// Search finds books whose name or authors contain any word of query, best
+// matches first.
+func (repo BookRepository) Search(query string, limit int) ([]SearchHit, error) {
+	limit, err := searchLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	terms := search.Tokenize(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	var hits []SearchHit
+	if repo.Dialect.isPostgres() && repo.Version >= SchemaSearch {
+		hits, err = repo.fullTextSearch(query, terms, limit)
+	} else {
+		var snapshot *searchSnapshot
+		snapshot, err = repo.cache().get(repo.GetAllBooks)
+		if err == nil {
+			hits = snapshot.search(query, limit)
+		}
+	}
+	if err != nil {
+		L.Error("Error ", err)
+		return nil, err
+	}
+	if len(hits) == 0 {
+		return nil, ErrNoBooks
+	}
+	return hits, nil
+}
+
+// fullTextSearch ranks with Postgres' ts_rank over the name (weight A) and
+// the author names (weight B). The book is picked through the GIN indexes
+// of migration 4; the words are ORed so a book matches on any of them.
+func (repo BookRepository) fullTextSearch(query string, terms []string, limit int) ([]SearchHit, error) {
+	tsquery := strings.Join(terms, " | ")
+	live := ""
+	if repo.live() != "" {
+		live = repo.live() + ` and `
+	}
+	cmd := `SELECT d.isbn, ts_rank(d.doc, to_tsquery('simple', $1)) AS rank from (` +
+		`SELECT b.isbn, setweight(to_tsvector('simple', coalesce(b.name, '')), 'A') || ` +
+		`setweight(to_tsvector('simple', coalesce(string_agg(a.name, ' '), '')), 'B') AS doc ` +
+		`from Book b LEFT JOIN book_author ba ON ba.id_book = b.isbn LEFT JOIN Author a ON a.id = ba.id_author ` +
+		`where ` + live + `(to_tsvector('simple', coalesce(b.name, '')) @@ to_tsquery('simple', $1) ` +
+		`or b.isbn IN (SELECT ba2.id_book from book_author ba2 JOIN Author a2 ON a2.id = ba2.id_author ` +
+		`where to_tsvector('simple', coalesce(a2.name, '')) @@ to_tsquery('simple', $1))) ` +
+		`GROUP BY b.isbn, b.name) d ORDER BY rank DESC, d.isbn LIMIT ` + strconv.Itoa(limit)
+	L.Info("Querying " + cmd)
+	row, err := repo.conn().Query(cmd, tsquery)
+	if err != nil {
+		return nil, err
+	}
+	isbns := []string{}
+	scores := map[string]float64{}
+	for row.Next() {
+		var isbn string
+		var score float64
+		if err := row.Scan(&isbn, &score); err != nil {
+			row.Close()
+			return nil, err
+		}
+		isbns = append(isbns, isbn)
+		scores[isbn] = score
+	}
+	row.Close()
+	if err := row.Err(); err != nil {
+		return nil, err
+	}
+	if len(isbns) == 0 {
+		return []SearchHit{}, nil
+	}
+
+	books, err := repo.getByISBNs(isbns)
+	if err != nil {
+		return nil, err
+	}
+	hits := []SearchHit{}
+	for _, book := range books {
+		hits = append(hits, newSearchHit(book, scores[book.ISBN], query))
+	}
+	return hits, nil
+}
+
+func (repo MemoryBookRepository) Search(query string, limit int) ([]SearchHit, error) {
+	limit, err := searchLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(search.Tokenize(query)) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	snapshot, err := repo.cache().get(repo.GetAllBooks)
+	if err != nil {
+		return nil, err
+	}
+	hits := snapshot.search(query, limit)
+	if len(hits) == 0 {
+		return nil, ErrNoBooks
+	}
+	return hits, nil
+}
+
+// Suggest completes prefix to titles and author names, tolerating a few
+// typos. It runs on the in-process index for every database, so it stays
+// cheap enough to call on each keystroke, and may lag writes by about
+// SuggestRebuildInterval while the index is rebuilt.
+func (repo BookRepository) Suggest(prefix string, limit int) ([]Suggestion, error) {
+	return suggest(repo.cache(), repo.GetAllBooks, prefix, limit)
+}
+
+func (repo MemoryBookRepository) Suggest(prefix string, limit int) ([]Suggestion, error) {
+	return suggest(repo.cache(), repo.GetAllBooks, prefix, limit)
+}
+
+func suggest(cache *searchCache, load func() ([]Book, error), prefix string, limit int) ([]Suggestion, error) {
+	if limit == 0 {
+		limit = DefaultSuggestions
+	}
+	if limit < 0 || limit > MaxLimit {
+		return nil, ErrInvalidLimit
+	}
+	if len(search.Tokenize(prefix)) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	snapshot, err := cache.latest(load)
+	if err != nil {
+		L.Error("Error ", err)
+		return nil, err
+	}
+	return snapshot.suggest(prefix, limit), nil
+}
@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SchemaSoftDelete is the migration that adds Book.deleted_at. From then on
+// Delete leaves a tombstone that reads skip, Restore takes it away again and
+// Purge removes the books for good once they have been deleted long enough.
+const SchemaSoftDelete uint = 7
+
+var (
+	ErrBookNotDeleted = NewError(ErrConflict, "Book is not deleted")
+	// ErrBookDeleted is a write that would bring back a deleted book,
+	// which only Restore does.
+	ErrBookDeleted = NewError(ErrConflict, "Book is deleted, restore it instead")
+)
+
+func (repo BookRepository) softDeletes() bool {
+	return repo.Version >= SchemaSoftDelete
+}
+
+// WithDeleted returns the repository whose reads see deleted books too. It
+// searches without the shared index, which only holds live books.
+func (repo BookRepository) WithDeleted() BookStore {
+	repo.withDeleted = true
+	return repo
+}
+
+// live is the condition that skips deleted books, or "" when there is none
+// to skip.
+func (repo BookRepository) live() string {
+	if !repo.softDeletes() || repo.withDeleted {
+		return ""
+	}
+	return `b.deleted_at IS NULL`
+}
+
+// tombstoned reports whether isbn is a deleted book, and keeps it from being
+// deleted or restored until the transaction it is called in ends.
+func (repo BookRepository) tombstoned(isbn string) (bool, error) {
+	cmd := `SELECT deleted_at IS NOT NULL from Book where isbn=$1`
+	if repo.tx != nil && repo.Dialect.Name != SQLite.Name {
+		cmd += ` FOR UPDATE`
+	}
+	L.Info("Querying " + cmd)
+	var deleted bool
+	err := repo.conn().QueryRow(cmd, isbn).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return deleted, err
+}
+
+// Restore takes the tombstone off a deleted book.
+func (repo BookRepository) Restore(isbn string) error {
+	if !repo.softDeletes() {
+		return ErrBookNotFound
+	}
+	return repo.write(isbn, func(repo BookRepository) error {
+		cmd := "UPDATE Book SET deleted_at = NULL, version = version + 1 WHERE isbn = $1 AND deleted_at IS NOT NULL"
+		res, err := repo.conn().Exec(cmd, isbn)
+		if err != nil {
+			return err
+		}
+		if restored, err := res.RowsAffected(); err != nil || restored > 0 {
+			return err
+		}
+		if _, err := repo.GetByISBN(isbn); err != nil {
+			return err
+		}
+		return ErrBookNotDeleted
+	})
+}
+
+// Purge removes for good the books deleted before cutoff, and returns how
+// many it removed.
+func (repo BookRepository) Purge(cutoff time.Time) (int, error) {
+	if !repo.softDeletes() {
+		return 0, nil
+	}
+	isbns := []string{}
+	err := repo.transaction(func(repo BookRepository) error {
+		cmd := `SELECT isbn from Book where deleted_at < $1`
+		L.Info("Querying " + cmd)
+		row, err := repo.conn().Query(cmd, cutoff.UTC())
+		if err != nil {
+			return err
+		}
+		for row.Next() {
+			var isbn string
+			if err := row.Scan(&isbn); err != nil {
+				row.Close()
+				return err
+			}
+			isbns = append(isbns, isbn)
+		}
+		row.Close()
+		if err := row.Err(); err != nil {
+			return err
+		}
+		for _, isbn := range isbns {
+			err := repo.write(isbn, func(repo BookRepository) error {
+				if _, err := repo.conn().Exec("DELETE FROM book_author WHERE id_book = $1", isbn); err != nil {
+					return err
+				}
+				_, err := repo.conn().Exec("DELETE FROM Book WHERE isbn = $1", isbn)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		L.Error("Error ", err)
+		return 0, err
+	}
+	return len(isbns), nil
+}
+
+func (repo MemoryBookRepository) WithDeleted() BookStore {
+	repo.withDeleted = true
+	return repo
+}
+
+// visible reports whether reads of the repository see stored.
+func (repo MemoryBookRepository) visible(stored memoryBook) bool {
+	return stored.deletedAt == nil || repo.withDeleted
+}
+
+func (repo MemoryBookRepository) Restore(isbn string) error {
+	return repo.db.update(repo.tx, func(tx *memoryTx) error {
+		stored, exists := tx.data.books[isbn]
+		if !exists {
+			return ErrBookNotFound
+		}
+		if stored.deletedAt == nil {
+			return ErrBookNotDeleted
+		}
+		tx.audit(isbn, repo.actor, func() {
+			stored.deletedAt = nil
+			tx.setBook(isbn, stored)
+		})
+		return nil
+	})
+}
+
+func (repo MemoryBookRepository) Purge(cutoff time.Time) (int, error) {
+	purged := 0
+	err := repo.db.update(repo.tx, func(tx *memoryTx) error {
+		for isbn, stored := range tx.data.books {
+			if stored.deletedAt != nil && stored.deletedAt.Before(cutoff) {
+				tx.audit(isbn, repo.actor, func() { tx.deleteBook(isbn) })
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// BookStore is what the service layer needs from a place that keeps books.
+// BookRepository keeps them in a SQL database, MemoryBookRepository in memory.
+type BookStore interface {
+	GetAllBooks() ([]Book, error)
+	GetByISBN(isbn string) (Book, error)
+	GetByAuthor(author string) ([]Book, error)
+	GetInRange(year1, year2 int) ([]Book, error)
+	ListBooks(filter BookFilter, page PageRequest) (BookPage, error)
+	// ListBooksAsOf lists the books as they were at the given time.
+	ListBooksAsOf(at time.Time, filter BookFilter, page PageRequest) (BookPage, error)
+	// EachBook calls fn with every book matching filter, in isbn order,
+	// one at a time, until fn fails.
+	EachBook(filter BookFilter, fn func(Book) error) error
+	Search(query string, limit int) ([]SearchHit, error)
+	Suggest(prefix string, limit int) ([]Suggestion, error)
+	Insert(isbn, name string, authors []string, publish_year int) (sql.Result, error)
+	// Update overwrites a stored book, failing with ErrBookNotFound when
+	// there is none.
+	Update(isbn, name string, authors []string, publish_year int) (sql.Result, error)
+	// LockVersion returns the version of a book and keeps it from changing
+	// until the transaction it is called in ends.
+	LockVersion(isbn string) (int, error)
+	// Upsert inserts the book or overwrites the one with the same isbn, and
+	// reports whether it was created. It does not overwrite a deleted book
+	// but fails with ErrBookDeleted.
+	Upsert(isbn, name string, authors []string, publish_year int) (bool, error)
+	// Delete removes a book. From SchemaSoftDelete on it leaves a tombstone
+	// until Purge.
+	Delete(isbn string) (sql.Result, error)
+	// Restore takes the tombstone off a deleted book.
+	Restore(isbn string) error
+	// Purge removes for good the books deleted before cutoff and returns
+	// how many it removed.
+	Purge(cutoff time.Time) (int, error)
+	// WithDeleted returns the store whose reads include deleted books.
+	WithDeleted() BookStore
+	// As returns the store recording actor in the history as the author of
+	// its writes.
+	As(actor string) BookStore
+	// History returns one page of the changes made to a book, oldest first
+	// unless the page is Desc.
+	History(isbn string, page PageRequest) (HistoryPage, error)
+	// Transaction runs fn against a store whose changes are kept only if
+	// fn returns nil.
+	Transaction(fn func(store BookStore) error) error
+}
+
+// AuthorStore is what the service layer needs from a place that keeps authors.
+type AuthorStore interface {
+	GetAll() ([]Author, error)
+	GetByID(id int) (Author, error)
+	GetByName(name string) (Author, error)
+	Insert(author Author) (Author, error)
+	Update(author Author) (sql.Result, error)
+	Delete(id int) (sql.Result, error)
+	// Transaction runs fn against a store whose changes are kept only if
+	// fn returns nil.
+	Transaction(fn func(store AuthorStore) error) error
+}
+
+var (
+	_ BookStore   = BookRepository{}
+	_ BookStore   = MemoryBookRepository{}
+	_ AuthorStore = AuthorRepository{}
+	_ AuthorStore = MemoryAuthorRepository{}
+)
+
+// MemoryURL is the DB_URL that selects the in-memory stores.
+const MemoryURL = "memory://"
+
+// NewStores builds the book and author stores for the DB_URL in the
+// environment: "memory://" keeps everything in memory, "sqlite://" uses a
+// SQLite file, "mysql://" a MySQL server and anything else is treated as a
+// Postgres connection string.
+// SQL databases are migrated on the way.
+func NewStores() (BookStore, AuthorStore, error) {
+	url := DatabaseURL()
+	if strings.HasPrefix(url, MemoryURL) {
+		L.Info("Using the in-memory store")
+		db := NewMemoryDB()
+		return NewMemoryBookRepository(db), NewMemoryAuthorRepository(db), nil
+	}
+
+	db, dialect, err := ConnectDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	books, err := NewBookRepository(db, dialect)
+	if err != nil {
+		return nil, nil, err
+	}
+	return books, books.Authors(), nil
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import "database/sql"
+
+// SchemaBookVersion is the migration that adds Book.version. It keeps the
+// v3 layout, so versioned schemas use the v3 queries plus the version.
+const SchemaBookVersion uint = 5
+
+var ErrVersionMismatch = NewError(ErrPrecondition, "Book was changed since the given version")
+
+func (repo BookRepository) versioned() bool {
+	return repo.Version >= SchemaBookVersion
+}
+
+// LockVersion returns the version of a book, or 0 before the schema has
+// one. Inside a transaction the book stays locked until the transaction
+// ends, so the version cannot change between this check and the write
+// that follows it. SQLite locks the whole database on the first write
+// instead.
+func (repo BookRepository) LockVersion(isbn string) (int, error) {
+	cmd := `SELECT 0 from Book where isbn=$1`
+	if repo.versioned() {
+		cmd = `SELECT version from Book where isbn=$1`
+	}
+	if repo.live() != "" {
+		cmd += ` and deleted_at IS NULL`
+	}
+	if repo.tx != nil && repo.Dialect.Name != SQLite.Name {
+		cmd += ` FOR UPDATE`
+	}
+	L.Info("Querying " + cmd)
+	var version int
+	if err := repo.conn().QueryRow(cmd, isbn).Scan(&version); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrBookNotFound
+		}
+		L.Error("Error ", err)
+		return 0, err
+	}
+	return version, nil
+}
+
+func (repo MemoryBookRepository) LockVersion(isbn string) (int, error) {
+	var version int
+	err := repo.db.view(repo.tx, func(data *memoryData) error {
+		stored, ok := data.books[isbn]
+		if !ok || !repo.visible(stored) {
+			return ErrBookNotFound
+		}
+		version = stored.version
+		return nil
+	})
+	return version, err
+}
@@ -0,0 +1,122 @@
+package repositories_test
+
+import (
+	repositories "server/repositories"
+	"testing"
+	"time"
+)
+
+// mark returns a time strictly between the writes made before and after it.
+func mark() time.Time {
+	time.Sleep(5 * time.Millisecond)
+	defer time.Sleep(5 * time.Millisecond)
+	return time.Now()
+}
+
+func TestAsOf(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store repositories.BookStore, _ repositories.AuthorStore) {
+		start := mark()
+		store.Insert("100", "Alpha", []string{"Victor"}, 2001)
+		inserted := mark()
+		store.Update("100", "Alpha 2", []string{"Victor"}, 2001)
+		store.Insert("200", "Beta", []string{"Albert"}, 2002)
+		updated := mark()
+		store.Delete("100")
+		deleted := mark()
+
+		cases := []struct {
+			at       time.Time
+			filter   repositories.BookFilter
+			expected []string
+		}{
+			{inserted, repositories.BookFilter{}, []string{"Alpha"}},
+			{updated, repositories.BookFilter{}, []string{"Alpha 2", "Beta"}},
+			{updated, repositories.BookFilter{Author: "Albert"}, []string{"Beta"}},
+			{deleted, repositories.BookFilter{}, []string{"Beta"}},
+		}
+		for _, c := range cases {
+			page, err := store.ListBooksAsOf(c.at, c.filter, repositories.PageRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, book := range page.Books {
+				names = append(names, book.Name)
+			}
+			if len(names) != len(c.expected) || page.Total != len(c.expected) {
+				t.Errorf("Expected %v as of %v, got %v", c.expected, c.at, names)
+				continue
+			}
+			for i := range names {
+				if names[i] != c.expected[i] {
+					t.Errorf("Expected %v as of %v, got %v", c.expected, c.at, names)
+				}
+			}
+		}
+		if _, err := store.ListBooksAsOf(start, repositories.BookFilter{}, repositories.PageRequest{}); err != repositories.ErrNoBooks {
+			t.Errorf("Expected no books before the first insert, got %v", err)
+		}
+
+		store.Insert("300", "Gamma", []string{"Albert"}, 2003)
+		request := repositories.PageRequest{Limit: 1, Sort: repositories.SortName, Desc: true}
+		names := []string{}
+		for {
+			page, err := store.ListBooksAsOf(updated, repositories.BookFilter{}, request)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != 2 || len(page.Books) != 1 {
+				t.Fatalf("Expected pages of one of two books, got %v", page)
+			}
+			names = append(names, page.Books[0].Name)
+			if request.Cursor = page.NextCursor; request.Cursor == "" {
+				break
+			}
+		}
+		if len(names) != 2 || names[0] != "Beta" || names[1] != "Alpha 2" {
+			t.Errorf("Expected Beta then Alpha 2, got %v", names)
+		}
+	})
+}
+
+// Books written before the history was kept have none, and are listed as
+// they are now at any time.
+func TestSQLiteAsOfWithoutHistory(t *testing.T) {
+	books, _ := newSQLite(t)
+	before := mark()
+	if _, err := books.DB.Exec("INSERT INTO Book (isbn, name, publish_year) VALUES ('100', 'Alpha', 2001)"); err != nil {
+		t.Fatal(err)
+	}
+	books.Insert("200", "Beta", []string{"Albert"}, 2002)
+
+	page, err := books.ListBooksAsOf(before, repositories.BookFilter{}, repositories.PageRequest{})
+	if err != nil || len(page.Books) != 1 || page.Books[0].Name != "Alpha" {
+		t.Errorf("Expected only the book without history, got %v, %v", page, err)
+	}
+	page, err = books.ListBooksAsOf(time.Now(), repositories.BookFilter{Author: "Albert"}, repositories.PageRequest{})
+	if err != nil || len(page.Books) != 1 || page.Books[0].Name != "Beta" {
+		t.Errorf("Expected the book by Albert, got %v, %v", page, err)
+	}
+}
+
+func TestSQLiteAsOfBeforeSoftDeletes(t *testing.T) {
+	books, _ := newSQLiteAt(t, repositories.SchemaBookHistory)
+	if books.Version != repositories.SchemaBookHistory {
+		t.Fatalf("Expected schema %d, got %d", repositories.SchemaBookHistory, books.Version)
+	}
+	if _, err := books.DB.Exec("INSERT INTO Book (isbn, name, publish_year) VALUES ('100', 'Alpha', 2001)"); err != nil {
+		t.Fatal(err)
+	}
+	books.Insert("200", "Beta", []string{"Albert"}, 2002)
+
+	page, err := books.ListBooksAsOf(time.Now(), repositories.BookFilter{}, repositories.PageRequest{})
+	if err != nil || page.Total != 2 {
+		t.Errorf("Expected both books, got %v, %v", page, err)
+	}
+}
+
+func TestAsOfNeedsHistory(t *testing.T) {
+	if _, err := repo.ListBooksAsOf(time.Now(), repositories.BookFilter{}, repositories.PageRequest{}); err != repositories.ErrNoHistory {
+		t.Errorf("Expected ErrNoHistory, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package repositories_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	repositories "server/repositories"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+var authorRepo = repositories.NewAuthorRepository(db, repositories.SchemaV3)
+
+func TestAuthorGetAll(t *testing.T) {
+	birth := time.Date(1961, 5, 2, 0, 0, 0, 0, time.UTC)
+	expected := []repositories.Author{
+		{ID: 1, Name: "Albert", BirthDate: &repositories.Date{Time: birth}},
+		{ID: 2, Name: "Victor"},
+	}
+	rows := sqlmock.NewRows([]string{"id", "name", "birth_date"}).
+		AddRow(1, "Albert", birth).
+		AddRow(2, "Victor", nil)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author")).WillReturnRows(rows)
+
+	authors, err := authorRepo.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(authors, expected) {
+		t.Errorf("Returned authors don't match expected authors. Expected: %v, Actual: %v", expected, authors)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthorGetByIDNotFound(t *testing.T) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author where id=$1")).
+		WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "birth_date"}))
+
+	_, err := authorRepo.GetByID(42)
+	if err != repositories.ErrAuthorNotFound {
+		t.Errorf("Expected ErrAuthorNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthorInsert(t *testing.T) {
+	birth := &repositories.Date{Time: time.Date(1990, 1, 31, 0, 0, 0, 0, time.UTC)}
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO Author (name, birth_date) VALUES ($1, $2) RETURNING id")).
+		WithArgs("Grahahm", birth.Time).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+
+	author, err := authorRepo.Insert(repositories.Author{Name: "Grahahm", BirthDate: birth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author.ID != 5 {
+		t.Errorf("Expected id 5, got %d", author.ID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestAuthorDeleteWithBooks(t *testing.T) {
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Author WHERE id = $1")).
+		WithArgs(3).
+		WillReturnError(&pq.Error{Code: "23503"})
+
+	_, err := authorRepo.Delete(3)
+	if err != repositories.ErrAuthorHasBooks {
+		t.Errorf("Expected ErrAuthorHasBooks, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	var author repositories.Author
+	if err := json.Unmarshal([]byte(`{"name":"Albert","birth_date":"1961-05-02"}`), &author); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := json.Marshal(author)
+	if string(out) != `{"id":0,"name":"Albert","birth_date":"1961-05-02"}` {
+		t.Errorf("Unexpected JSON %s", out)
+	}
+}
@@ -0,0 +1,37 @@
+package repositories_test
+
+import (
+	"errors"
+	"fmt"
+	"server/repositories"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	cases := map[error]error{
+		repositories.ErrBookNotFound:                      repositories.ErrNotFound,
+		repositories.ErrNoBooks:                           repositories.ErrNotFound,
+		repositories.ErrAuthorNotFound:                    repositories.ErrNotFound,
+		repositories.ErrBookExists:                        repositories.ErrConflict,
+		repositories.ErrAuthorHasBooks:                    repositories.ErrConflict,
+		repositories.ErrSingleAuthor:                      repositories.ErrInvalid,
+		repositories.ErrInvalidCursor:                     repositories.ErrBadRequest,
+		repositories.ErrVersionMismatch:                   repositories.ErrPrecondition,
+		repositories.ErrSomethingWentWrong:                repositories.ErrInternal,
+		fmt.Errorf("123: %w", repositories.ErrBookExists): repositories.ErrConflict,
+	}
+	for err, kind := range cases {
+		if !errors.Is(err, kind) {
+			t.Errorf("Expected %q to be of kind %q", err, kind)
+		}
+	}
+
+	cause := errors.New("check digit does not match")
+	err := repositories.WithKind(repositories.ErrInvalid, cause)
+	if !errors.Is(err, cause) || !errors.Is(err, repositories.ErrInvalid) || err.Error() != cause.Error() {
+		t.Errorf("Expected WithKind to keep the cause, got %v", err)
+	}
+	if errors.Is(repositories.ErrBookNotFound, repositories.ErrConflict) {
+		t.Error("Expected an error to match its own kind only")
+	}
+}
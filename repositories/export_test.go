@@ -0,0 +1,48 @@
+package repositories_test
+
+import (
+	"errors"
+	"reflect"
+	repositories "server/repositories"
+	"testing"
+)
+
+func TestEachBook(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store repositories.BookStore) {
+		store.Delete("103")
+
+		books := []repositories.Book{}
+		err := store.EachBook(repositories.BookFilter{}, func(book repositories.Book) error {
+			books = append(books, book)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isbns(books); !reflect.DeepEqual(got, []string{"100", "101", "102", "104"}) {
+			t.Errorf("Expected the live books in isbn order, got %v", got)
+		}
+		if len(books) == 4 && (len(books[0].Authors) != 2 || len(books[3].Authors) != 2) {
+			t.Errorf("Expected every author of each book, got %v", books)
+		}
+
+		albert := []repositories.Book{}
+		store.EachBook(repositories.BookFilter{Author: "Albert"}, func(book repositories.Book) error {
+			albert = append(albert, book)
+			return nil
+		})
+		if got := isbns(albert); !reflect.DeepEqual(got, []string{"100", "101", "104"}) {
+			t.Errorf("Expected Albert's books, got %v", got)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = store.EachBook(repositories.BookFilter{}, func(book repositories.Book) error {
+			calls++
+			return stop
+		})
+		if err != stop || calls != 1 {
+			t.Errorf("Expected the walk to stop at the first error, got %v after %d calls", err, calls)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package repositories_test
+
+import (
+	"errors"
+	"reflect"
+	repositories "server/repositories"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store repositories.BookStore, _ repositories.AuthorStore) {
+		librarian := store.As("librarian")
+		librarian.Insert("100", "Alpha", []string{"Victor"}, 2001)
+		store.Update("100", "Alpha 2", []string{"Victor"}, 2002)
+		librarian.Delete("100")
+		store.Transaction(func(store repositories.BookStore) error {
+			store.Insert("100", "Rolled back", nil, 2003)
+			return errors.New("boom")
+		})
+		store.Insert("200", "Beta", nil, 2004)
+
+		history, err := store.History("100", repositories.PageRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions := []string{}
+		for _, change := range history.Changes {
+			actions = append(actions, change.Action+" by "+change.Actor)
+			if change.ChangedAt.IsZero() {
+				t.Errorf("Expected %v to have a time", change)
+			}
+		}
+		expected := []string{"insert by librarian", "update by anonymous", "delete by librarian"}
+		if !reflect.DeepEqual(actions, expected) {
+			t.Fatalf("Expected %v, got %v", expected, actions)
+		}
+		update := history.Changes[1]
+		if update.Before == nil || update.Before.Name != "Alpha" || update.After == nil || update.After.Name != "Alpha 2" || update.After.Version != 2 {
+			t.Errorf("Expected the update to keep both versions of the book, got %v and %v", update.Before, update.After)
+		}
+		if history.Changes[0].Before != nil || history.Changes[2].After == nil || history.Changes[2].After.DeletedAt == nil {
+			t.Errorf("Expected no book before the insert and a tombstone after the delete, got %v", history.Changes)
+		}
+
+		page, err := store.History("100", repositories.PageRequest{Limit: 2, Desc: true})
+		if err != nil || len(page.Changes) != 2 || page.Changes[0].Action != repositories.ActionDelete || page.NextCursor == "" {
+			t.Fatalf("Expected the newest two changes and a cursor, got %v, %v", page, err)
+		}
+		page, err = store.History("100", repositories.PageRequest{Limit: 2, Desc: true, Cursor: page.NextCursor})
+		if err != nil || len(page.Changes) != 1 || page.Changes[0].Action != repositories.ActionInsert || page.NextCursor != "" {
+			t.Errorf("Expected the insert on the last page, got %v, %v", page, err)
+		}
+		if _, err := store.History("100", repositories.PageRequest{Cursor: "bogus"}); err != repositories.ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package repositories_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	repositories "server/repositories"
+	"sync"
+	"testing"
+)
+
+func newMemory() (*repositories.MemoryBookRepository, *repositories.MemoryAuthorRepository) {
+	db := repositories.NewMemoryDB()
+	return repositories.NewMemoryBookRepository(db), repositories.NewMemoryAuthorRepository(db)
+}
+
+func TestMemoryInsertAndGet(t *testing.T) {
+	books, authors := newMemory()
+
+	if _, err := books.Insert("12235670", "Skinner", []string{"Albert", "Victor"}, 2001); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := books.Insert("19123450", "Atomic", []string{"Albert"}, 2022); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := books.Insert("19123450", "Atomic", []string{"Albert"}, 2022); err != repositories.ErrBookExists {
+		t.Errorf("Expected ErrBookExists, got %v", err)
+	}
+
+	expected := []repositories.Book{
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert", "Victor"}, PublishYear: 2001, Version: 1},
+		{ISBN: "19123450", Name: "Atomic", Authors: []string{"Albert"}, PublishYear: 2022, Version: 1},
+	}
+	got, err := books.GetByAuthor("Albert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Returned books don't match expected books. Expected: %v, Actual: %v", expected, got)
+	}
+
+	got, _ = books.GetInRange(2010, 2030)
+	if !reflect.DeepEqual(got, expected[1:]) {
+		t.Errorf("Returned books don't match expected books. Expected: %v, Actual: %v", expected[1:], got)
+	}
+
+	all, _ := authors.GetAll()
+	if len(all) != 2 {
+		t.Errorf("Expected 2 authors, got %v", all)
+	}
+	if _, err := authors.Delete(all[0].ID); err != repositories.ErrAuthorHasBooks {
+		t.Errorf("Expected ErrAuthorHasBooks, got %v", err)
+	}
+}
+
+func TestMemoryUpdateAndDelete(t *testing.T) {
+	books, _ := newMemory()
+	books.Insert("12235670", "Skinner", []string{"Albert"}, 2001)
+
+	res, _ := books.Update("12235670", "Skinner 2", []string{"Victor"}, 2002)
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("Expected 1 updated row, got %d", n)
+	}
+	book, _ := books.GetByISBN("12235670")
+	expected := repositories.Book{ISBN: "12235670", Name: "Skinner 2", Authors: []string{"Victor"}, PublishYear: 2002, Version: 2}
+	if !reflect.DeepEqual(book, expected) {
+		t.Errorf("Expected %v, got %v", expected, book)
+	}
+
+	books.Delete("12235670")
+	if _, err := books.GetByISBN("12235670"); err != repositories.ErrBookNotFound {
+		t.Errorf("Expected ErrBookNotFound, got %v", err)
+	}
+}
+
+func TestMemoryTransactionRollsBack(t *testing.T) {
+	books, authors := newMemory()
+	books.Insert("12235670", "Skinner", []string{"Albert"}, 2001)
+
+	boom := errors.New("boom")
+	err := books.Transaction(func(store repositories.BookStore) error {
+		store.Insert("19123450", "Atomic", []string{"Grahahm"}, 2022)
+		store.Update("12235670", "Changed", []string{"Victor"}, 1999)
+		store.Delete("12235670")
+		return boom
+	})
+	if err != boom {
+		t.Errorf("Expected boom, got %v", err)
+	}
+
+	all, _ := books.GetAllBooks()
+	expected := []repositories.Book{
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert"}, PublishYear: 2001, Version: 1},
+	}
+	if !reflect.DeepEqual(all, expected) {
+		t.Errorf("Expected %v after rollback, got %v", expected, all)
+	}
+	if _, err := authors.GetByName("Grahahm"); err != repositories.ErrAuthorNotFound {
+		t.Errorf("Expected the author created in the transaction to be gone, got %v", err)
+	}
+}
+
+func TestMemoryConcurrentInserts(t *testing.T) {
+	books, _ := newMemory()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			books.Insert(fmt.Sprintf("isbn-%02d", i), "Book", []string{"Albert"}, 2000+i)
+			books.GetByAuthor("Albert")
+		}(i)
+	}
+	wg.Wait()
+
+	all, _ := books.GetAllBooks()
+	if len(all) != 50 {
+		t.Errorf("Expected 50 books, got %d", len(all))
+	}
+}
@@ -0,0 +1,156 @@
+package repositories_test
+
+import (
+	"reflect"
+	"regexp"
+	repositories "server/repositories"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// walk follows NextCursor until the listing runs out and returns every isbn
+// in the order the pages served them.
+func walk(t *testing.T, store repositories.BookStore, filter repositories.BookFilter, page repositories.PageRequest) []string {
+	seen := []string{}
+	for {
+		result, err := store.ListBooks(filter, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Books) > page.Limit {
+			t.Fatalf("Page of %d books is over the limit of %d", len(result.Books), page.Limit)
+		}
+		seen = append(seen, isbns(result.Books)...)
+		if result.NextCursor == "" {
+			return seen
+		}
+		page.Cursor = result.NextCursor
+	}
+}
+
+func TestPagination(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store repositories.BookStore) {
+		got := walk(t, store, repositories.BookFilter{}, repositories.PageRequest{Limit: 2, Sort: repositories.SortName})
+		if expected := []string{"101", "102", "100", "104", "103"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v by name, got %v", expected, got)
+		}
+
+		got = walk(t, store, repositories.BookFilter{}, repositories.PageRequest{Limit: 3, Sort: repositories.SortPublishYear, Desc: true})
+		if expected := []string{"104", "101", "102", "100", "103"}; !reflect.DeepEqual(got, expected) {
+			t.Errorf("Expected %v by publish_year desc, got %v", expected, got)
+		}
+
+		from, to := 2000, 2010
+		filter := repositories.BookFilter{Author: "Albert", FromYear: &from, ToYear: &to}
+		first, err := store.ListBooks(filter, repositories.PageRequest{Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.Total != 3 || !reflect.DeepEqual(isbns(first.Books), []string{"100"}) {
+			t.Errorf("Expected 100 out of 3 books, got %v out of %d", isbns(first.Books), first.Total)
+		}
+		if got := walk(t, store, filter, repositories.PageRequest{Limit: 1}); !reflect.DeepEqual(got, []string{"100", "101", "104"}) {
+			t.Errorf("Expected Albert's books from 2000 to 2010, got %v", got)
+		}
+
+		_, err = store.ListBooks(filter, repositories.PageRequest{Sort: repositories.SortName, Cursor: first.NextCursor})
+		if err != repositories.ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor for a cursor of another order, got %v", err)
+		}
+		if _, err := store.ListBooks(filter, repositories.PageRequest{Sort: "author"}); err != repositories.ErrInvalidSort {
+			t.Errorf("Expected ErrInvalidSort, got %v", err)
+		}
+		if _, err := store.ListBooks(filter, repositories.PageRequest{Limit: repositories.MaxLimit + 1}); err != repositories.ErrInvalidLimit {
+			t.Errorf("Expected ErrInvalidLimit, got %v", err)
+		}
+	})
+}
+
+func TestListBooks(t *testing.T) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) from Book b where b.publish_year>=$1")).
+		WithArgs(2000).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT b.isbn from Book b where b.publish_year>=$1 ORDER BY b.name DESC, b.isbn DESC LIMIT 3")).
+		WithArgs(2000).
+		WillReturnRows(sqlmock.NewRows([]string{"isbn"}).AddRow("12235670").AddRow("19123450").AddRow("19123451"))
+	mock.ExpectQuery(regexp.QuoteMeta("where b.isbn IN ($1,$2)")).
+		WithArgs("12235670", "19123450").
+		WillReturnRows(sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+			AddRow("19123450", "Atomic", 2022, "Grahahm").
+			AddRow("12235670", "Skinner", 2001, "Albert").
+			AddRow("12235670", "Skinner", 2001, "Victor"))
+
+	from := 2000
+	page, err := repo.ListBooks(repositories.BookFilter{FromYear: &from}, repositories.PageRequest{Limit: 2, Sort: repositories.SortName, Desc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []repositories.Book{
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert", "Victor"}, PublishYear: 2001},
+		{ISBN: "19123450", Name: "Atomic", Authors: []string{"Grahahm"}, PublishYear: 2022},
+	}
+	if !reflect.DeepEqual(page.Books, expected) {
+		t.Errorf("Returned books don't match expected books. Expected: %v, Actual: %v", expected, page.Books)
+	}
+	if page.Total != 3 || page.NextCursor == "" {
+		t.Errorf("Expected a next cursor and a total of 3, got %q and %d", page.NextCursor, page.Total)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store repositories.BookStore) {
+		if _, err := store.Insert("2001", "100% Alpha_Beta", []string{"Victor"}, 2015); err != nil {
+			t.Fatal(err)
+		}
+
+		from := 2005
+		cases := []struct {
+			filter   repositories.BookFilter
+			expected []string
+		}{
+			{repositories.BookFilter{Title: "alp"}, []string{"101", "102", "2001"}},
+			{repositories.BookFilter{Title: "alp", Author: "Victor"}, []string{"102", "2001"}},
+			{repositories.BookFilter{Title: "alp", Author: "Victor", FromYear: &from}, []string{"2001"}},
+			{repositories.BookFilter{Title: "0% a"}, []string{"2001"}},
+			{repositories.BookFilter{Title: "a_b"}, []string{"2001"}},
+			{repositories.BookFilter{ISBNPrefix: "10"}, []string{"100", "101", "102", "103", "104"}},
+			{repositories.BookFilter{ISBNPrefix: "10", Author: "Grahahm"}, []string{"104"}},
+			{repositories.BookFilter{ISBN: "103", Author: "Albert"}, []string{"103"}},
+		}
+		for _, c := range cases {
+			page, err := store.ListBooks(c.filter, repositories.PageRequest{})
+			if err != nil {
+				t.Errorf("%+v: %s", c.filter, err)
+				continue
+			}
+			if got := isbns(page.Books); !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("%+v: expected %v, got %v", c.filter, c.expected, got)
+			}
+		}
+
+		if _, err := store.ListBooks(repositories.BookFilter{Title: "a%"}, repositories.PageRequest{}); err != repositories.ErrNoBooks {
+			t.Errorf("Expected %% to be matched literally, got %v", err)
+		}
+	})
+}
+
+func TestListBooksFilterQuery(t *testing.T) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) from Book b where b.isbn LIKE $1 ESCAPE '!' and b.isbn IN (SELECT ba2.id_book from book_author ba2 JOIN Author a2 ON a2.id = ba2.id_author where a2.name=$2) and LOWER(b.name) LIKE $3 ESCAPE '!'`)).
+		WithArgs("97!_%", "Albert", "%s!%%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT b.isbn from Book b where")).
+		WithArgs("97!_%", "Albert", "%s!%%").
+		WillReturnRows(sqlmock.NewRows([]string{"isbn"}))
+
+	filter := repositories.BookFilter{ISBNPrefix: "97_", Author: "Albert", Title: "S%"}
+	if _, err := repo.ListBooks(filter, repositories.PageRequest{}); err != repositories.ErrNoBooks {
+		t.Errorf("Expected ErrNoBooks, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
@@ -29,16 +29,17 @@ var repo = repositories.BookRepository{
 
 func TestGetAllBooks(t *testing.T) {
 	expected := []repositories.Book{
-		{ISBN: "19123450", Name: "Atomic", Author: "Grahahm", PublishYear: 2022},
-		{ISBN: "12235670", Name: "Skinner", Author: "Albert", PublishYear: 2001},
-		{ISBN: "12223900", Name: "Short", Author: "Victor", PublishYear: 1998},
+		{ISBN: "19123450", Name: "Atomic", Authors: []string{"Grahahm"}, PublishYear: 2022},
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert", "Victor"}, PublishYear: 2001},
+		{ISBN: "12223900", Name: "Short", Authors: []string{}, PublishYear: 1998},
 	}
-	rows := sqlmock.NewRows([]string{"isbn", "name", "author", "publish_year"}).
-		AddRow("19123450", "Atomic", "Grahahm", 2022).
-		AddRow("12235670", "Skinner", "Albert", 2001).
-		AddRow("12223900", "Short", "Victor", 1998)
+	rows := sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+		AddRow("19123450", "Atomic", 2022, "Grahahm").
+		AddRow("12235670", "Skinner", 2001, "Albert").
+		AddRow("12235670", "Skinner", 2001, "Victor").
+		AddRow("12223900", "Short", 1998, nil)
 
-	mock.ExpectQuery("SELECT isbn,name,author,publish_year from Book").WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT b.isbn,b.name,b.publish_year,a.name from Book b LEFT JOIN book_author ba")).WillReturnRows(rows)
 
 	book, err := repo.GetAllBooks()
 	if err != nil {
@@ -55,11 +56,11 @@ func TestGetAllBooks(t *testing.T) {
 
 func TestGetByISBN(t *testing.T) {
 	expected := []repositories.Book{
-		{ISBN: "12235670", Name: "Skinner", Author: "Albert", PublishYear: 2001},
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert"}, PublishYear: 2001},
 	}
 
-	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "author", "publish_year"}).
-		AddRow("12235670", "Skinner", "Albert", 2001)
+	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "publish_year", "author"}).
+		AddRow("12235670", "Skinner", 2001, "Albert")
 	mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(expectedRows)
 
 	book, err := repo.GetByISBN("12235670")
@@ -77,13 +78,16 @@ func TestGetByISBN(t *testing.T) {
 
 func TestGetByAuthor(t *testing.T) {
 	expected := []repositories.Book{
-		{ISBN: "12235670", Name: "Skinner", Author: "Albert", PublishYear: 2001},
-		{ISBN: "12289970", Name: "Stlake", Author: "Albert", PublishYear: 1997},
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert", "Victor"}, PublishYear: 2001},
+		{ISBN: "12289970", Name: "Stlake", Authors: []string{"Albert"}, PublishYear: 1997},
 	}
-	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "author", "publish_year"}).
-		AddRow("12235670", "Skinner", "Albert", 2001).
-		AddRow("12289970", "Stlake", "Albert", 1997)
-	mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(expectedRows)
+	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "publish_year", "author"}).
+		AddRow("12235670", "Skinner", 2001, "Albert").
+		AddRow("12235670", "Skinner", 2001, "Victor").
+		AddRow("12289970", "Stlake", 1997, "Albert")
+	mock.ExpectQuery(`SELECT (.*) where b.isbn IN \(SELECT (.*) where a2.name=\$1\)`).
+		WithArgs("Albert").
+		WillReturnRows(expectedRows)
 
 	book, err := repo.GetByAuthor("Albert")
 	if err != nil {
@@ -100,12 +104,12 @@ func TestGetByAuthor(t *testing.T) {
 
 func TestGetInRange(t *testing.T) {
 	expected := []repositories.Book{
-		{ISBN: "12235670", Name: "Skinner", Author: "Albert", PublishYear: 2001},
-		{ISBN: "19123450", Name: "Atomic", Author: "Grahahm", PublishYear: 2022},
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert"}, PublishYear: 2001},
+		{ISBN: "19123450", Name: "Atomic", Authors: []string{"Grahahm"}, PublishYear: 2022},
 	}
-	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "author", "publish_year"}).
-		AddRow("12235670", "Skinner", "Albert", 2001).
-		AddRow("19123450", "Atomic", "Grahahm", 2022)
+	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "publish_year", "author"}).
+		AddRow("12235670", "Skinner", 2001, "Albert").
+		AddRow("19123450", "Atomic", 2022, "Grahahm")
 	mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(expectedRows)
 
 	book, err := repo.GetInRange(1999, 2023)
@@ -125,14 +129,34 @@ func TestUpdate(t *testing.T) {
 
 	isbn := "19123450"
 	newName := "Updated"
-	newAuthor := "Updated Author"
+	newAuthors := []string{"Updated Author", "Co Author"}
 	newPublishYear := 2019
 
-	mock.ExpectExec(regexp.QuoteMeta("UPDATE Book SET name = $1, publish_year = $2, author = $3 WHERE isbn = $4")).
-		WithArgs(newName, newPublishYear, newAuthor, isbn).
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE Book SET name = $1, publish_year = $2 WHERE isbn = $3")).
+		WithArgs(newName, newPublishYear, isbn).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM book_author WHERE id_book = $1")).
+		WithArgs(isbn).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author where name=$1")).
+		WithArgs(newAuthors[0]).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "birth_date"}).AddRow(7, newAuthors[0], nil))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO book_author (id_book, id_author) VALUES ($1, $2)")).
+		WithArgs(isbn, 7).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author where name=$1")).
+		WithArgs(newAuthors[1]).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "birth_date"}))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO Author (name, birth_date) VALUES ($1, $2) RETURNING id")).
+		WithArgs(newAuthors[1], nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO book_author (id_book, id_author) VALUES ($1, $2)")).
+		WithArgs(isbn, 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	_, err := repo.Update(isbn, newName, newAuthor, newPublishYear)
+	_, err := repo.Update(isbn, newName, newAuthors, newPublishYear)
 	if err != nil {
 		t.Errorf("Error when updating db")
 	}
@@ -146,9 +170,14 @@ func TestDelete(t *testing.T) {
 
 	isbn := "19123450"
 
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM book_author WHERE id_book = $1")).
+		WithArgs(isbn).
+		WillReturnResult(sqlmock.NewResult(0, 2))
 	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Book WHERE isbn = $1")).
 		WithArgs(isbn).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
 	_, err := repo.Delete(isbn)
 	if err != nil {
@@ -167,11 +196,22 @@ func TestInsert(t *testing.T) {
 	newAuthor := "Updated Author"
 	newPublishYear := 2019
 
-	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year, author) VALUES ($1, $2, $3, $4)")).
-		WithArgs(isbn, newName, newPublishYear, newAuthor).
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year) VALUES ($1, $2, $3)")).
+		WithArgs(isbn, newName, newPublishYear).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author where name=$1")).
+		WithArgs(newAuthor).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "birth_date"}))
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO Author (name, birth_date) VALUES ($1, $2) RETURNING id")).
+		WithArgs(newAuthor, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO book_author (id_book, id_author) VALUES ($1, $2)")).
+		WithArgs(isbn, 8).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	_, err := repo.Insert(isbn, newName, newAuthor, newPublishYear)
+	_, err := repo.Insert(isbn, newName, []string{newAuthor, newAuthor}, newPublishYear)
 	if err != nil {
 		t.Errorf("Error when inserting db")
 	}
@@ -180,3 +220,26 @@ func TestInsert(t *testing.T) {
 		t.Errorf("Unfulfilled expectations: %s", err)
 	}
 }
+
+func TestInsertRollsBack(t *testing.T) {
+
+	isbn := "19123450"
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year) VALUES ($1, $2, $3)")).
+		WithArgs(isbn, "Name", 2019).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author where name=$1")).
+		WithArgs("Author").
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	_, err := repo.Insert(isbn, "Name", []string{"Author"}, 2019)
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,116 @@
+package repositories_test
+
+import (
+	"reflect"
+	"regexp"
+	repositories "server/repositories"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var repoV1 = repositories.BookRepository{
+	DB:      db,
+	Table:   "Book",
+	Version: repositories.SchemaV1,
+}
+
+var repoV2 = repositories.BookRepository{
+	DB:      db,
+	Table:   "Book",
+	Version: repositories.SchemaV2,
+}
+
+func TestV1GetByAuthor(t *testing.T) {
+	expected := []repositories.Book{
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert"}, PublishYear: 2001},
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT b.isbn,b.name,b.publish_year,b.author from Book b where b.author=$1")).
+		WithArgs("Albert").
+		WillReturnRows(sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+			AddRow("12235670", "Skinner", 2001, "Albert"))
+
+	books, err := repoV1.GetByAuthor("Albert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(books, expected) {
+		t.Errorf("Returned books don't match expected books. Expected: %v, Actual: %v", expected, books)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestV1Insert(t *testing.T) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year, author) VALUES ($1, $2, $3, $4)")).
+		WithArgs("19123450", "Atomic", 2022, "Grahahm").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := repoV1.Insert("19123450", "Atomic", []string{"Grahahm"}, 2022); err != nil {
+		t.Errorf("Error when inserting db: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestV1InsertRejectsCoAuthors(t *testing.T) {
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	_, err := repoV1.Insert("19123450", "Atomic", []string{"Grahahm", "Albert"}, 2022)
+	if err != repositories.ErrSingleAuthor {
+		t.Errorf("Expected ErrSingleAuthor, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestV2GetAllBooks(t *testing.T) {
+	expected := []repositories.Book{
+		{ISBN: "19123450", Name: "Atomic", Authors: []string{"Grahahm"}, PublishYear: 2022},
+	}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT b.isbn,b.name,b.publish_year,a.name from Book b LEFT JOIN Author a ON a.id = b.id_author")).
+		WillReturnRows(sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+			AddRow("19123450", "Atomic", 2022, "Grahahm"))
+
+	books, err := repoV2.GetAllBooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(books, expected) {
+		t.Errorf("Returned books don't match expected books. Expected: %v, Actual: %v", expected, books)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestV2Update(t *testing.T) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author where name=$1")).
+		WithArgs("Albert").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "birth_date"}).AddRow(4, "Albert", nil))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE Book SET name = $1, publish_year = $2, id_author = $3 WHERE isbn = $4")).
+		WithArgs("Skinner", 2001, 4, "12235670").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := repoV2.Update("12235670", "Skinner", []string{"Albert"}, 2001); err != nil {
+		t.Errorf("Error when updating db: %s", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestV1HasNoAuthors(t *testing.T) {
+	authors := repositories.NewAuthorRepository(db, repositories.SchemaV1)
+	if _, err := authors.GetAll(); err != repositories.ErrNoAuthors {
+		t.Errorf("Expected ErrNoAuthors, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package repositories_test
+
+import (
+	"reflect"
+	"regexp"
+	repositories "server/repositories"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func hitISBNs(hits []repositories.SearchHit) []string {
+	result := []string{}
+	for _, hit := range hits {
+		result = append(result, hit.Book.ISBN)
+	}
+	return result
+}
+
+func TestSearch(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store repositories.BookStore) {
+		hits, err := store.Search("alpha victor", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hitISBNs(hits); !reflect.DeepEqual(got, []string{"102", "101", "100"}) {
+			t.Errorf("Expected the book matching both words first, got %v", got)
+		}
+		expected := repositories.Highlight{Name: "<mark>Alpha</mark>", Authors: []string{"<mark>Victor</mark>"}}
+		if !reflect.DeepEqual(hits[0].Highlight, expected) {
+			t.Errorf("Expected highlight %v, got %v", expected, hits[0].Highlight)
+		}
+
+		if _, err := store.Search("omega", 0); err != repositories.ErrNoBooks {
+			t.Errorf("Expected ErrNoBooks, got %v", err)
+		}
+		if _, err := store.Insert("105", "Omega", []string{"Grahahm"}, 2020); err != nil {
+			t.Fatal(err)
+		}
+		if hits, err := store.Search("omega", 0); err != nil || !reflect.DeepEqual(hitISBNs(hits), []string{"105"}) {
+			t.Errorf("Expected the new book to be found, got %v, %v", hitISBNs(hits), err)
+		}
+		if _, err := store.Delete("105"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Search("omega", 0); err != repositories.ErrNoBooks {
+			t.Errorf("Expected the deleted book to be gone, got %v", err)
+		}
+
+		if _, err := store.Search(" -- ", 0); err != repositories.ErrEmptyQuery {
+			t.Errorf("Expected ErrEmptyQuery, got %v", err)
+		}
+	})
+}
+
+func TestPostgresFullTextSearch(t *testing.T) {
+	repoSearch := repositories.BookRepository{DB: db, Table: "Book", Version: repositories.SchemaSearch}
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT d.isbn, ts_rank(d.doc, to_tsquery('simple', $1)) AS rank")).
+		WithArgs("atomic | habits").
+		WillReturnRows(sqlmock.NewRows([]string{"isbn", "rank"}).AddRow("19123450", 0.6).AddRow("12235670", 0.1))
+	mock.ExpectQuery(regexp.QuoteMeta("where b.isbn IN ($1,$2)")).
+		WithArgs("19123450", "12235670").
+		WillReturnRows(sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+			AddRow("12235670", "Habits", 2001, "Albert").
+			AddRow("19123450", "Atomic Habits", 2022, "Grahahm"))
+
+	hits, err := repoSearch.Search("Atomic habits!", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hitISBNs(hits); !reflect.DeepEqual(got, []string{"19123450", "12235670"}) {
+		t.Errorf("Expected the ts_rank order, got %v", got)
+	}
+	if hits[0].Score != 0.6 || hits[0].Highlight.Name != "<mark>Atomic</mark> <mark>Habits</mark>" {
+		t.Errorf("Unexpected first hit %+v", hits[0])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+// eventually polls done until it holds, giving up after a few rebuilds of
+// the suggestion index.
+func eventually(done func() bool) bool {
+	deadline := time.Now().Add(3 * repositories.SuggestRebuildInterval)
+	for !done() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return true
+}
+
+func TestSuggest(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store repositories.BookStore) {
+		suggestions, err := store.Suggest("alb", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []repositories.Suggestion{{Text: "Albert", Kind: repositories.SuggestAuthor, Books: 4}}
+		if !reflect.DeepEqual(suggestions, expected) {
+			t.Errorf("Expected %v, got %v", expected, suggestions)
+		}
+
+		suggestions, _ = store.Suggest("Alpah", 0)
+		expected = []repositories.Suggestion{{Text: "Alpha", Kind: repositories.SuggestTitle, Books: 2}}
+		if !reflect.DeepEqual(suggestions, expected) {
+			t.Errorf("Expected the typo to be forgiven, got %v", suggestions)
+		}
+
+		if _, err := store.Insert("105", "Gamma Rays", []string{"Victor"}, 2020); err != nil {
+			t.Fatal(err)
+		}
+		suggestions, _ = store.Suggest("gam", 0)
+		if len(suggestions) != 1 || suggestions[0].Text != "Gamma" {
+			t.Errorf("Expected the index from before the write while it is rebuilt, got %v", suggestions)
+		}
+		rebuilt := eventually(func() bool {
+			suggestions, _ = store.Suggest("gam", 0)
+			return len(suggestions) == 2 && suggestions[0].Text == "Gamma" && suggestions[1].Text == "Gamma Rays"
+		})
+		if !rebuilt {
+			t.Errorf("Expected both Gamma titles once the index is rebuilt, got %v", suggestions)
+		}
+
+		if suggestions, err := store.Suggest("zzz", 0); err != nil || len(suggestions) != 0 {
+			t.Errorf("Expected no suggestions and no error, got %v, %v", suggestions, err)
+		}
+		if _, err := store.Suggest("", 0); err != repositories.ErrEmptyQuery {
+			t.Errorf("Expected ErrEmptyQuery, got %v", err)
+		}
+	})
+}
+
+func TestSearchAfterAuthorRename(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store repositories.BookStore, authors repositories.AuthorStore) {
+		if _, err := store.Insert("100", "Alpha", []string{"Albert"}, 2000); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Search("albert", 0); err != nil {
+			t.Fatal(err)
+		}
+		author, err := authors.GetByName("Albert")
+		if err != nil {
+			t.Fatal(err)
+		}
+		author.Name = "Zelda"
+		if _, err := authors.Update(author); err != nil {
+			t.Fatal(err)
+		}
+
+		if hits, err := store.Search("zelda", 0); err != nil || !reflect.DeepEqual(hitISBNs(hits), []string{"100"}) {
+			t.Errorf("Expected the renamed author to be found, got %v, %v", hitISBNs(hits), err)
+		}
+		if _, err := store.Search("albert", 0); err != repositories.ErrNoBooks {
+			t.Errorf("Expected the old name to be gone, got %v", err)
+		}
+		suggestions, _ := store.Suggest("zel", 0)
+		expected := []repositories.Suggestion{{Text: "Zelda", Kind: repositories.SuggestAuthor, Books: 1}}
+		if !reflect.DeepEqual(suggestions, expected) {
+			t.Errorf("Expected %v, got %v", expected, suggestions)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package repositories_test
+
+import (
+	"reflect"
+	repositories "server/repositories"
+	"testing"
+	"time"
+)
+
+func TestSoftDelete(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store repositories.BookStore) {
+		if _, err := store.Delete("100"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := store.GetByISBN("100"); err != repositories.ErrBookNotFound {
+			t.Errorf("Expected a deleted book to be gone, got %v", err)
+		}
+		if _, err := store.LockVersion("100"); err != repositories.ErrBookNotFound {
+			t.Errorf("Expected a deleted book to be gone, got %v", err)
+		}
+		page, err := store.ListBooks(repositories.BookFilter{Author: "Victor"}, repositories.PageRequest{})
+		if err != nil || page.Total != 1 || page.Books[0].ISBN != "102" {
+			t.Errorf("Expected only the live book to be listed, got %v, %v", page, err)
+		}
+		deleted, err := store.WithDeleted().GetByISBN("100")
+		if err != nil || deleted.DeletedAt == nil || !reflect.DeepEqual(deleted.Authors, []string{"Albert", "Victor"}) {
+			t.Errorf("Expected the tombstone with its authors, got %v, %v", deleted, err)
+		}
+
+		if err := store.Restore("100"); err != nil {
+			t.Fatal(err)
+		}
+		if book, err := store.GetByISBN("100"); err != nil || book.DeletedAt != nil {
+			t.Errorf("Expected the book to be back, got %v, %v", book, err)
+		}
+		if err := store.Restore("100"); err != repositories.ErrBookNotDeleted {
+			t.Errorf("Expected ErrBookNotDeleted, got %v", err)
+		}
+		if err := store.Restore("999"); err != repositories.ErrBookNotFound {
+			t.Errorf("Expected ErrBookNotFound, got %v", err)
+		}
+
+		store.Delete("100")
+		store.Delete("102")
+		if _, err := store.Upsert("102", "Alpha 2", []string{"Victor"}, 2002); err != repositories.ErrBookDeleted {
+			t.Errorf("Expected the upsert to leave the tombstone alone, got %v", err)
+		}
+		if book, err := store.WithDeleted().GetByISBN("102"); err != nil || book.DeletedAt == nil || book.Name != "Alpha" {
+			t.Errorf("Expected the book to stay deleted as it was, got %v, %v", book, err)
+		}
+		if err := store.Restore("102"); err != nil {
+			t.Fatal(err)
+		}
+		if created, err := store.Upsert("102", "Alpha 2", []string{"Victor"}, 2002); err != nil || created {
+			t.Errorf("Expected the restored book to be overwritten, got %v, %v", created, err)
+		}
+		if purged, err := store.Purge(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+			t.Errorf("Expected nothing deleted an hour ago, got %d, %v", purged, err)
+		}
+		if purged, err := store.Purge(time.Now().Add(time.Second)); err != nil || purged != 1 {
+			t.Errorf("Expected one book to be purged, got %d, %v", purged, err)
+		}
+		if _, err := store.WithDeleted().GetByISBN("100"); err != repositories.ErrBookNotFound {
+			t.Errorf("Expected the purged book to be gone for good, got %v", err)
+		}
+		if book, err := store.GetByISBN("102"); err != nil || book.Name != "Alpha 2" {
+			t.Errorf("Expected the upserted book to be live, got %v, %v", book, err)
+		}
+
+		history, _ := store.History("100", repositories.PageRequest{})
+		actions := []string{}
+		for _, change := range history.Changes {
+			actions = append(actions, change.Action)
+		}
+		expected := []string{"insert", "delete", "restore", "delete", "purge"}
+		if !reflect.DeepEqual(actions, expected) {
+			t.Errorf("Expected history %v, got %v", expected, actions)
+		}
+	})
+}
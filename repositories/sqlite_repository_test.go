@@ -0,0 +1,131 @@
+package repositories_test
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"server/db/migration"
+	repositories "server/repositories"
+	"testing"
+)
+
+func newSQLite(t *testing.T) (*repositories.BookRepository, *repositories.AuthorRepository) {
+	return newSQLiteAt(t, 0)
+}
+
+// newSQLiteAt is newSQLite with the schema migrated up to target only, or
+// all the way with 0.
+func newSQLiteAt(t *testing.T, target uint) (*repositories.BookRepository, *repositories.AuthorRepository) {
+	dialect, dsn := repositories.ParseURL("sqlite://:memory:")
+	db, err := sql.Open(dialect.Driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	if _, err := migration.Up(db, dialect.Migrations, target); err != nil {
+		t.Fatal(err)
+	}
+	books, err := repositories.NewBookRepository(db, dialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return books, books.Authors()
+}
+
+func TestParseURL(t *testing.T) {
+	dialect, dsn := repositories.ParseURL("sqlite://books.db")
+	if dialect.Name != "sqlite" || dsn != "books.db?_foreign_keys=on" {
+		t.Errorf("Unexpected sqlite dialect %s with dsn %s", dialect.Name, dsn)
+	}
+	dialect, dsn = repositories.ParseURL("postgres://postgres@localhost/books")
+	if dialect.Name != "postgres" || dsn != "postgres://postgres@localhost/books" {
+		t.Errorf("Unexpected postgres dialect %s with dsn %s", dialect.Name, dsn)
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query, args := repositories.SQLite.Rebind("SELECT 1 where y<=$2 and y>=$1", []any{1999, 2023})
+	if query != "SELECT 1 where y<=? and y>=?" || !reflect.DeepEqual(args, []any{2023, 1999}) {
+		t.Errorf("Unexpected rebind %s %v", query, args)
+	}
+}
+
+func TestSQLiteBooks(t *testing.T) {
+	books, authors := newSQLite(t)
+
+	if _, err := books.Insert("12235670", "Skinner", []string{"Albert", "Victor"}, 2001); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := books.Insert("19123450", "Atomic", []string{"Grahahm"}, 2022); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := books.Insert("19123450", "Atomic", nil, 2022); err != repositories.ErrBookExists {
+		t.Errorf("Expected ErrBookExists, got %v", err)
+	}
+
+	got, err := books.GetInRange(1999, 2010)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []repositories.Book{
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert", "Victor"}, PublishYear: 2001, Version: 1},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Returned books don't match expected books. Expected: %v, Actual: %v", expected, got)
+	}
+
+	if _, err := books.Update("12235670", "Skinner", []string{"Victor"}, 2001); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = books.GetByAuthor("Victor")
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Authors, []string{"Victor"}) {
+		t.Errorf("Unexpected books for Victor: %v", got)
+	}
+
+	victor, err := authors.GetByName("Victor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authors.Delete(victor.ID); err != repositories.ErrAuthorHasBooks {
+		t.Errorf("Expected ErrAuthorHasBooks, got %v", err)
+	}
+
+	if _, err := books.Delete("12235670"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := books.GetByISBN("12235670"); err != repositories.ErrBookNotFound {
+		t.Errorf("Expected ErrBookNotFound, got %v", err)
+	}
+}
+
+func TestSQLiteAuthorTransaction(t *testing.T) {
+	_, authors := newSQLite(t)
+	albert, err := authors.Insert(repositories.Author{Name: "Albert"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("failed")
+	err = authors.Transaction(func(store repositories.AuthorStore) error {
+		if _, err := store.Update(repositories.Author{ID: albert.ID, Name: "Alberto"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Expected the error of fn, got %v", err)
+	}
+	if author, err := authors.GetByID(albert.ID); err != nil || author.Name != "Albert" {
+		t.Errorf("Expected the rename to be rolled back, got %v, %v", author, err)
+	}
+
+	all, err := authors.GetAll()
+	if err != nil || len(all) != 1 {
+		t.Errorf("Expected Albert alone, got %v, %v", all, err)
+	}
+	authors.Delete(albert.ID)
+	if all, err := authors.GetAll(); err != nil || len(all) != 0 {
+		t.Errorf("Expected no authors and no error, got %v, %v", all, err)
+	}
+}
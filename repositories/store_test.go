@@ -0,0 +1,66 @@
+package repositories_test
+
+import (
+	repositories "server/repositories"
+	"testing"
+)
+
+// backends are the stores the shared tests run against. Each test gets a
+// fresh one, whose author store shares its database.
+var backends = []struct {
+	name string
+	open func(t *testing.T) (repositories.BookStore, repositories.AuthorStore)
+}{
+	{"Memory", func(t *testing.T) (repositories.BookStore, repositories.AuthorStore) {
+		books, authors := newMemory()
+		return books, authors
+	}},
+	{"SQLite", func(t *testing.T) (repositories.BookStore, repositories.AuthorStore) {
+		books, authors := newSQLite(t)
+		return books, authors
+	}},
+}
+
+// forEachBackend runs test as a subtest against an empty store of every
+// backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, books repositories.BookStore, authors repositories.AuthorStore)) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			books, authors := backend.open(t)
+			test(t, books, authors)
+		})
+	}
+}
+
+// forEachStore runs test as a subtest against a store of every backend
+// holding the books of seed.
+func forEachStore(t *testing.T, test func(t *testing.T, store repositories.BookStore)) {
+	forEachBackend(t, func(t *testing.T, books repositories.BookStore, _ repositories.AuthorStore) {
+		seed(t, books)
+		test(t, books)
+	})
+}
+
+// seed stores the books the shared tests are written against.
+func seed(t *testing.T, store repositories.BookStore) {
+	books := []repositories.Book{
+		{ISBN: "100", Name: "Beta", Authors: []string{"Albert", "Victor"}, PublishYear: 2001},
+		{ISBN: "101", Name: "Alpha", Authors: []string{"Albert"}, PublishYear: 2010},
+		{ISBN: "102", Name: "Alpha", Authors: []string{"Victor"}, PublishYear: 2001},
+		{ISBN: "103", Name: "Gamma", Authors: []string{"Albert"}, PublishYear: 1999},
+		{ISBN: "104", Name: "Delta", Authors: []string{"Albert", "Grahahm"}, PublishYear: 2010},
+	}
+	for _, book := range books {
+		if _, err := store.Insert(book.ISBN, book.Name, book.Authors, book.PublishYear); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func isbns(books []repositories.Book) []string {
+	result := []string{}
+	for _, book := range books {
+		result = append(result, book.ISBN)
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package repositories_test
+
+import (
+	"regexp"
+	repositories "server/repositories"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func testUpdateMissingBook(t *testing.T, store repositories.BookStore) {
+	if _, err := store.Update("999", "Omega", []string{"Albert"}, 2001); err != repositories.ErrBookNotFound {
+		t.Errorf("Expected ErrBookNotFound, got %v", err)
+	}
+	if _, err := store.GetByISBN("999"); err != repositories.ErrBookNotFound {
+		t.Errorf("Expected no book to be created, got %v", err)
+	}
+
+	// An update that changes nothing still finds the book.
+	if _, err := store.Update("101", "Alpha", []string{"Albert"}, 2010); err != nil {
+		t.Errorf("Expected an unchanged book to be updated, got %v", err)
+	}
+}
+
+func TestUpdateMissingBook(t *testing.T) {
+	forEachStore(t, testUpdateMissingBook)
+}
+
+func TestSQLiteUpdateMissingBookBeforeVersions(t *testing.T) {
+	books, _ := newSQLiteAt(t, 3)
+	seed(t, books)
+	testUpdateMissingBook(t, books)
+}
+
+func TestUpdateMissingBookLeavesAuthorsAlone(t *testing.T) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE Book SET name = $1, publish_year = $2 WHERE isbn = $3")).
+		WithArgs("Omega", 2001, "999").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM Book WHERE isbn = $1")).
+		WithArgs("999").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+
+	if _, err := repo.Update("999", "Omega", []string{"Albert"}, 2001); err != repositories.ErrBookNotFound {
+		t.Errorf("Expected ErrBookNotFound, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,69 @@
+package repositories_test
+
+import (
+	"reflect"
+	"regexp"
+	repositories "server/repositories"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestUpsert(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store repositories.BookStore) {
+		created, err := store.Upsert("105", "Omega", []string{"Victor"}, 2001)
+		if err != nil || !created {
+			t.Fatalf("Expected the book to be created, got %v, %v", created, err)
+		}
+		created, err = store.Upsert("100", "Beta 2", []string{"Grahahm", "Albert"}, 2002)
+		if err != nil || created {
+			t.Fatalf("Expected the book to be updated, got %v, %v", created, err)
+		}
+		book, err := store.GetByISBN("100")
+		expected := repositories.Book{ISBN: "100", Name: "Beta 2", Authors: []string{"Grahahm", "Albert"}, PublishYear: 2002, Version: 2}
+		if err != nil || !reflect.DeepEqual(book, expected) {
+			t.Errorf("Expected %v, got %v, %v", expected, book, err)
+		}
+	})
+}
+
+func TestUpsertReportsUpdate(t *testing.T) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year) VALUES ($1, $2, $3) ON CONFLICT (isbn) DO UPDATE SET name = EXCLUDED.name, publish_year = EXCLUDED.publish_year RETURNING (xmax = 0)")).
+		WithArgs("19123450", "Atomic", 2022).
+		WillReturnRows(sqlmock.NewRows([]string{"inserted"}).AddRow(false))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM book_author WHERE id_book = $1")).
+		WithArgs("19123450").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author where name=$1")).
+		WithArgs("Grahahm").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "birth_date"}).AddRow(7, "Grahahm", nil))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO book_author (id_book, id_author) VALUES ($1, $2)")).
+		WithArgs("19123450", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	created, err := repo.Upsert("19123450", "Atomic", []string{"Grahahm"}, 2022)
+	if err != nil || created {
+		t.Errorf("Expected an update, got %v, %v", created, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMySQLUpsert(t *testing.T) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year, author) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name), publish_year = VALUES(publish_year), author = VALUES(author)")).
+		WithArgs("19123450", "Atomic", 2022, "Grahahm").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	created, err := repoMySQL.Upsert("19123450", "Atomic", []string{"Grahahm"}, 2022)
+	if err != nil || created {
+		t.Errorf("Expected an update, got %v, %v", created, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,49 @@
+package repositories_test
+
+import (
+	"regexp"
+	repositories "server/repositories"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestVersions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store repositories.BookStore) {
+		if version, err := store.LockVersion("100"); err != nil || version != 1 {
+			t.Errorf("Expected a new book to be at version 1, got %d, %v", version, err)
+		}
+		store.Update("100", "Beta 2", []string{"Victor"}, 2002)
+		store.Upsert("100", "Beta 3", []string{"Victor"}, 2003)
+		book, err := store.GetByISBN("100")
+		if err != nil || book.Version != 3 {
+			t.Errorf("Expected every write to bump the version, got %v, %v", book, err)
+		}
+		if _, err := store.LockVersion("999"); err != repositories.ErrBookNotFound {
+			t.Errorf("Expected ErrBookNotFound, got %v", err)
+		}
+	})
+}
+
+func TestLockVersionForUpdate(t *testing.T) {
+	versioned := repositories.BookRepository{DB: db, Table: "Book", Version: repositories.SchemaBookVersion}
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version from Book where isbn=$1 FOR UPDATE")).
+		WithArgs("100").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	mock.ExpectCommit()
+
+	err := versioned.Transaction(func(store repositories.BookStore) error {
+		version, err := store.LockVersion("100")
+		if version != 4 {
+			t.Errorf("Expected version 4, got %d", version)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
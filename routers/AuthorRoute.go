@@ -0,0 +1,104 @@
+package routers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	repo "server/repositories"
+	"server/service"
+	"strconv"
+)
+
+var AuthorService service.AuthorService
+
+var (
+	ErrInvalidID          = repo.NewError(repo.ErrBadRequest, "id must be a number")
+	ErrInvalidAuthorsBody = repo.NewError(repo.ErrBadRequest, "Request body must be a JSON array of authors")
+)
+
+func writeJSON(w http.ResponseWriter, status int, response *Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+func GetAuthors(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	var (
+		result any
+		err    error
+	)
+	switch {
+	case params.Has("id"):
+		L.Info("GET /api/v1/authors?{id}")
+		id, errID := strconv.Atoi(params.Get("id"))
+		if errID != nil {
+			writeError(w, r, ErrInvalidID)
+			return
+		}
+		result, err = AuthorService.GetByID(id)
+	case params.Has("name"):
+		L.Info("GET /api/v1/authors?{name}")
+		result, err = AuthorService.GetByName(params.Get("name"))
+	default:
+		L.Info("GET /api/v1/authors")
+		result, err = AuthorService.GetAll()
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: result})
+}
+
+func readAuthors(w http.ResponseWriter, r *http.Request) ([]repo.Author, bool) {
+	body, _ := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	var authorData []repo.Author
+	if err := json.Unmarshal(body, &authorData); err != nil {
+		L.Error("Error: ", err)
+		writeError(w, r, ErrInvalidAuthorsBody)
+		return nil, false
+	}
+	return authorData, true
+}
+
+func InsertAuthors(w http.ResponseWriter, r *http.Request) {
+	L.Info("POST /api/v1/authors/add")
+	authorData, ok := readAuthors(w, r)
+	if !ok {
+		return
+	}
+	created, err := AuthorService.Insert(authorData)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: created})
+}
+
+func UpdateAuthors(w http.ResponseWriter, r *http.Request) {
+	L.Info("POST /api/v1/authors/update")
+	authorData, ok := readAuthors(w, r)
+	if !ok {
+		return
+	}
+	if err := AuthorService.Update(authorData); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: ""})
+}
+
+func DeleteAuthors(w http.ResponseWriter, r *http.Request) {
+	L.Info("DELETE /api/v1/authors/delete")
+	authorData, ok := readAuthors(w, r)
+	if !ok {
+		return
+	}
+	if err := AuthorService.Delete(authorData); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: ""})
+}
@@ -1,41 +1,71 @@
 package routers
 
 import (
+	"bytes"
 	"encoding/json"
-	_ "fmt"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"server/isbn"
 	"server/logger"
+	"server/patch"
 	repo "server/repositories"
 	"server/service"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Response struct {
-	Status  string `json:"status"`
-	Message any    `json:"message"`
+	Status  string               `json:"status"`
+	Message any                  `json:"message"`
+	Results []service.ItemResult `json:"results,omitempty"`
+	// NextCursor and Total come with paginated listings.
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
 }
 
-var BookRepo, _ = repo.NewBookRepository()
-var BookService = service.BookService{
-	Repo: BookRepo,
-}
+var BookService service.BookService
+
+var (
+	ErrInvalidOrder = repo.NewError(repo.ErrBadRequest, "Order must be asc or desc")
+	ErrInvalidYear  = repo.NewError(repo.ErrBadRequest, "Publish years must be numbers")
+	ErrInvalidAsOf  = repo.NewError(repo.ErrBadRequest, "as_of must be an RFC 3339 time such as 2024-03-18T00:00:00Z")
+	ErrInvalidBody  = repo.NewError(repo.ErrBadRequest, "Request body must be a JSON array of books")
+	ErrInvalidBook  = repo.NewError(repo.ErrBadRequest, "Request body must be a JSON book")
+	ErrISBNMismatch = repo.NewError(repo.ErrInvalid, "The isbn of the body does not match the one of the path")
+	ErrBulkIfMatch  = repo.NewError(repo.ErrBadRequest, "If-Match only applies to a single book, send the version of each book in the body instead")
+	// ErrUnsupportedPatch is a PATCH in a format other than the ones of
+	// acceptPatch.
+	ErrUnsupportedPatch = errors.New("Patch must be a JSON Merge Patch or a JSON Patch")
+)
+
+// Media types of the patch formats PATCH accepts. Plain JSON is read as a
+// merge patch.
+const (
+	mergePatch  = "application/merge-patch+json"
+	jsonPatch   = "application/json-patch+json"
+	acceptPatch = mergePatch + ", " + jsonPatch
+)
+
 var L = logger.CreateLog()
 
-func GetAllBooks(w http.ResponseWriter, r *http.Request) {
-	L.Info("GET /api/v1/books")
-	books, err := BookService.GetAllBooks()
+// Init picks the stores from DB_URL and wires the services on top of them.
+func Init() error {
+	books, authors, err := repo.NewStores()
 	if err != nil {
-		L.Error("Error: ", err)
-		response := &Response{Status: "fail", Message: err.Error()}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-		return
+		return err
 	}
-	response := &Response{Status: "success", Message: books}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	BookService = service.BookService{
+		Repo: books,
+	}
+	AuthorService = service.AuthorService{
+		Repo: authors,
+	}
+	return startPurge()
 }
 
 func GetByISBN(w http.ResponseWriter, r *http.Request) {
@@ -44,122 +74,499 @@ func GetByISBN(w http.ResponseWriter, r *http.Request) {
 	params, _ := url.ParseQuery(Url.RawQuery)
 	book, err := BookService.GetByISBN(params["isbn"][0])
 	if err != nil {
-		L.Error("Error: ", err)
-		response := &Response{Status: "fail", Message: err.Error()}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		writeError(w, r, err)
 		return
 	}
 	response := &Response{Status: "success", Message: book}
+	setETag(w, book)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func GetByAuthor(w http.ResponseWriter, r *http.Request) {
-	L.Info("GET /api/v1/books?{author}")
-	Url, _ := url.Parse(r.URL.String())
-	params, _ := url.ParseQuery(Url.RawQuery)
-	books, err := BookService.GetByAuthor(params["author"][0])
+func GetInRange(w http.ResponseWriter, r *http.Request) {
+	L.Info("GET /api/v1/books/range")
+	filter, err := bookFilter(r)
 	if err != nil {
-		L.Error("Error: ", err)
-		response := &Response{Status: "fail", Message: err.Error()}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		writeError(w, r, err)
 		return
 	}
-	response := &Response{Status: "success", Message: books}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	listBooks(w, r, filter)
 }
 
-func GetInRange(w http.ResponseWriter, r *http.Request) {
-	L.Info("GET /api/v1/books/range")
-	Url, _ := url.Parse(r.URL.String())
-	params, _ := url.ParseQuery(Url.RawQuery)
-	from, _ := strconv.Atoi(params["from"][0])
-	to, _ := strconv.Atoi(params["to"][0])
+// Search serves GET /api/v1/books/search?q=&limit=, the books matching any
+// word of q in their name or authors, best matches first.
+func Search(w http.ResponseWriter, r *http.Request) {
+	L.Info("GET /api/v1/books/search")
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+	hits, err := BookService.Search(r.URL.Query().Get("q"), limit)
+	if errors.Is(err, repo.ErrNoBooks) {
+		hits, err = []repo.SearchHit{}, nil
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: hits})
+}
 
-	books, err := BookService.GetInRange(from, to)
+// Suggest serves GET /api/v1/suggest?prefix=&limit=, titles and author
+// names completing prefix for an as-you-type box.
+func Suggest(w http.ResponseWriter, r *http.Request) {
+	L.Info("GET /api/v1/suggest")
+	limit, ok := queryLimit(w, r)
+	if !ok {
+		return
+	}
+	suggestions, err := BookService.Suggest(r.URL.Query().Get("prefix"), limit)
 	if err != nil {
-		L.Error("Error: ", err)
-		response := &Response{Status: "fail", Message: err.Error()}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		writeError(w, r, err)
 		return
 	}
-	response := &Response{Status: "success", Message: books}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: suggestions})
 }
 
-func Get(w http.ResponseWriter, r *http.Request) {
-	Url, _ := url.Parse(r.URL.String())
-	params, _ := url.ParseQuery(Url.RawQuery)
-	if len(params) == 0 {
-		GetAllBooks(w, r)
+// queryLimit reads ?limit=, answering 400 itself when it is not a positive
+// number. Zero means the default.
+func queryLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := r.URL.Query()
+	if !params.Has("limit") {
+		return 0, true
 	}
-	if _, ok := params["isbn"]; ok {
-		GetByISBN(w, r)
+	limit, err := strconv.Atoi(params.Get("limit"))
+	if err != nil || limit < 1 {
+		writeError(w, r, repo.ErrInvalidLimit)
+		return 0, false
 	}
-	if _, ok := params["author"]; ok {
-		GetByAuthor(w, r)
+	return limit, true
+}
+
+// bookFilter reads the listing filters, which all have to match:
+// ?author=, ?title= (substring), ?isbn_prefix=, ?from= and ?to= (years).
+func bookFilter(r *http.Request) (repo.BookFilter, error) {
+	params := r.URL.Query()
+	filter := repo.BookFilter{
+		ISBN:       params.Get("isbn"),
+		ISBNPrefix: params.Get("isbn_prefix"),
+		Author:     params.Get("author"),
+		Title:      params.Get("title"),
+	}
+	for name, bound := range map[string]**int{"from": &filter.FromYear, "to": &filter.ToYear} {
+		if !params.Has(name) {
+			continue
+		}
+		year, err := strconv.Atoi(params.Get(name))
+		if err != nil {
+			return filter, ErrInvalidYear
+		}
+		*bound = &year
 	}
+	return filter, nil
 }
 
-func Update(w http.ResponseWriter, r *http.Request) {
-	L.Info("POST /api/v1/books/update")
-	body, _ := io.ReadAll(r.Body)
-	defer r.Body.Close()
-	var bookData []repo.Book
-	_ = json.Unmarshal([]byte(string(body)), &bookData)
-	err := BookService.Update(bookData)
+// pageRequest reads ?limit=, ?sort=isbn|name|publish_year, ?order=asc|desc
+// and ?cursor= for the listing endpoints.
+func pageRequest(r *http.Request) (repo.PageRequest, error) {
+	params := r.URL.Query()
+	page := repo.PageRequest{
+		Sort:   params.Get("sort"),
+		Cursor: params.Get("cursor"),
+	}
+	if params.Has("limit") {
+		limit, err := strconv.Atoi(params.Get("limit"))
+		if err != nil || limit < 1 {
+			return page, repo.ErrInvalidLimit
+		}
+		page.Limit = limit
+	}
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		page.Desc = true
+	default:
+		return page, ErrInvalidOrder
+	}
+	return page, nil
+}
+
+// asOf reads ?as_of=, the RFC 3339 time a listing is about, or nil for
+// the catalog as it is now.
+func asOf(r *http.Request) (*time.Time, error) {
+	params := r.URL.Query()
+	if !params.Has("as_of") {
+		return nil, nil
+	}
+	at, err := time.Parse(time.RFC3339, params.Get("as_of"))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		response := &Response{Status: "fail", Message: err.Error()}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		return nil, ErrInvalidAsOf
+	}
+	return &at, nil
+}
+
+// listBooks answers with one page of the books matching filter. A listing
+// that matches nothing is an empty page, not an error.
+func listBooks(w http.ResponseWriter, r *http.Request, filter repo.BookFilter) {
+	page, err := pageRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	at, err := asOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	catalog, err := reader(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	var books repo.BookPage
+	if at != nil {
+		books, err = BookService.ListAsOf(*at, filter, page)
 	} else {
-		response := &Response{Status: "success", Message: ""}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		books, err = catalog.List(filter, page)
+	}
+	if errors.Is(err, repo.ErrNoBooks) {
+		books, err = repo.BookPage{Books: []repo.Book{}}, nil
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: books.Books, NextCursor: books.NextCursor, Total: &books.Total})
+}
+
+// Get serves GET /api/v1/books, a page of the books matching all of the
+// filters, deleted ones too for admins with ?include_deleted=true. With
+// ?as_of= it lists the catalog as it was at that time instead. A lone
+// ?isbn= is the deprecated way to get that one book, which now lives at
+// /api/v1/books/{isbn}.
+func Get(w http.ResponseWriter, r *http.Request) {
+	filter, err := bookFilter(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if filter.ISBN != "" && filter == (repo.BookFilter{ISBN: filter.ISBN}) && !r.URL.Query().Has("as_of") {
+		Deprecated("/api/v1/books/"+isbn.Key(filter.ISBN), GetByISBN)(w, r)
+		return
 	}
+	L.Info("GET /api/v1/books")
+	listBooks(w, r, filter)
 }
 
+// Update serves POST /api/v1/books:batchUpdate.
+func Update(w http.ResponseWriter, r *http.Request) {
+	L.Info(r.Method + " " + r.URL.Path)
+	bookData, ok := readBooks(w, r)
+	if !ok {
+		return
+	}
+	if err := bulkIfMatch(r, bookData); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if partial(r) {
+		results, err := BookService.As(actorOf(r)).UpdatePartial(bookData)
+		writeBulkResult(w, r, results, err)
+		return
+	}
+	results, err := BookService.As(actorOf(r)).Update(bookData)
+	writeBulkResult(w, r, results, err)
+}
+
+// Delete serves POST /api/v1/books:batchDelete.
 func Delete(w http.ResponseWriter, r *http.Request) {
-	L.Info("DELETE /api/v1/books/delete")
-	body, _ := io.ReadAll(r.Body)
+	L.Info(r.Method + " " + r.URL.Path)
+	bookData, ok := readBooks(w, r)
+	if !ok {
+		return
+	}
+	if err := bulkIfMatch(r, bookData); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if partial(r) {
+		results, err := BookService.As(actorOf(r)).DeletePartial(bookData)
+		writeBulkResult(w, r, results, err)
+		return
+	}
+	results, err := BookService.As(actorOf(r)).Delete(bookData)
+	writeBulkResult(w, r, results, err)
+}
+
+// Insert serves POST /api/v1/books:batchCreate.
+func Insert(w http.ResponseWriter, r *http.Request) {
+	L.Info(r.Method + " " + r.URL.Path)
+	bookData, ok := readBooks(w, r)
+	if !ok {
+		return
+	}
+	if partial(r) {
+		results, err := BookService.As(actorOf(r)).InsertPartial(bookData)
+		writeBulkResult(w, r, results, err)
+		return
+	}
+	results, err := BookService.As(actorOf(r)).Insert(bookData)
+	writeBulkResult(w, r, results, err)
+}
+
+// Upsert serves PUT /api/v1/books, creating the books that are new and
+// overwriting the others. Each result says which of the two happened, so a
+// sync can send its whole batch without looking anything up first.
+func Upsert(w http.ResponseWriter, r *http.Request) {
+	L.Info("PUT /api/v1/books")
+	bookData, ok := readBooks(w, r)
+	if !ok {
+		return
+	}
+	if err := bulkIfMatch(r, bookData); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if partial(r) {
+		results, err := BookService.As(actorOf(r)).UpsertPartial(bookData)
+		writeBulkResult(w, r, results, err)
+		return
+	}
+	results, err := BookService.As(actorOf(r)).Upsert(bookData)
+	writeBulkResult(w, r, results, err)
+}
+
+// bulkIfMatch applies the If-Match header of a bulk write to its book, as
+// ReplaceBook does. A header names the version of one book, so it is
+// refused with more than one; their versions go in the body instead.
+func bulkIfMatch(r *http.Request, bookData []repo.Book) error {
+	if strings.TrimSpace(r.Header.Get("If-Match")) == "" {
+		return nil
+	}
+	if len(bookData) != 1 {
+		return ErrBulkIfMatch
+	}
+	expected, err := ifMatch(r, bookData[0].ISBN)
+	if err != nil {
+		return err
+	}
+	if expected != 0 {
+		bookData[0].Version = expected
+	}
+	return nil
+}
+
+// readBooks decodes the books of a write, answering 400 itself when the
+// body is not a JSON array of books.
+func readBooks(w http.ResponseWriter, r *http.Request) ([]repo.Book, bool) {
 	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
 	var bookData []repo.Book
-	_ = json.Unmarshal([]byte(string(body)), &bookData)
-	err := BookService.Delete(bookData)
+	if err == nil {
+		err = json.Unmarshal(body, &bookData)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		response := &Response{Status: "fail", Message: err.Error()}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	} else {
-		response := &Response{Status: "success", Message: ""}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		L.Error("Error: ", err)
+		writeError(w, r, ErrInvalidBody)
+		return nil, false
 	}
+	return bookData, true
+}
 
+// readBook decodes the single book of a write, answering 400 itself when the
+// body is not a JSON object.
+func readBook(w http.ResponseWriter, r *http.Request) (repo.Book, bool) {
+	defer r.Body.Close()
+	var book repo.Book
+	if err := json.NewDecoder(r.Body).Decode(&book); err != nil {
+		L.Error("Error: ", err)
+		writeError(w, r, ErrInvalidBook)
+		return book, false
+	}
+	return book, true
 }
 
-func Insert(w http.ResponseWriter, r *http.Request) {
-	L.Info("POST /api/v1/books/add")
-	body, _ := io.ReadAll(r.Body)
+// GetBook serves GET /api/v1/books/{isbn}, and deleted books too for admins
+// with ?include_deleted=true.
+func GetBook(w http.ResponseWriter, r *http.Request) {
+	L.Info("GET /api/v1/books/{isbn}")
+	catalog, err := reader(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	book, err := catalog.GetByISBN(r.PathValue("isbn"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setETag(w, book)
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: book})
+}
+
+// CreateBook serves POST /api/v1/books, answering 201 with the book and
+// where it now lives.
+func CreateBook(w http.ResponseWriter, r *http.Request) {
+	L.Info("POST /api/v1/books")
+	book, ok := readBook(w, r)
+	if !ok {
+		return
+	}
+	created, err := BookService.As(actorOf(r)).Create(book)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/books/"+created.ISBN)
+	setETag(w, created)
+	writeJSON(w, http.StatusCreated, &Response{Status: "success", Message: created})
+}
+
+// ReplaceBook serves PUT /api/v1/books/{isbn}, overwriting every field of
+// the book. The body may leave out the isbn, the path is enough. The version
+// the book has to be at comes from If-Match, or else from the body.
+func ReplaceBook(w http.ResponseWriter, r *http.Request) {
+	L.Info("PUT /api/v1/books/{isbn}")
+	book, ok := readBook(w, r)
+	if !ok {
+		return
+	}
+	number := r.PathValue("isbn")
+	if book.ISBN != "" && isbn.Key(book.ISBN) != isbn.Key(number) {
+		writeError(w, r, ErrISBNMismatch)
+		return
+	}
+	book.ISBN = number
+	expected, err := ifMatch(r, number)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if expected != 0 {
+		book.Version = expected
+	}
+	replaced, err := BookService.As(actorOf(r)).Replace(book)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setETag(w, replaced)
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: replaced})
+}
+
+// PatchBook serves PATCH /api/v1/books/{isbn}. The body is a JSON Merge
+// Patch (RFC 7396) or a JSON Patch (RFC 6902) of the book as it is returned
+// by GetBook, and only the fields it names change. Its version is ignored,
+// the one the book has to be at comes from If-Match.
+func PatchBook(w http.ResponseWriter, r *http.Request) {
+	L.Info("PATCH /api/v1/books/{isbn}")
 	defer r.Body.Close()
-	var bookData []repo.Book
-	_ = json.Unmarshal([]byte(string(body)), &bookData)
-	err := BookService.Insert(bookData)
+	w.Header().Set("Accept-Patch", acceptPatch)
+	var apply func(doc, patch []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatch, "application/json":
+		apply = patch.Merge
+	case jsonPatch:
+		apply = patch.Apply
+	default:
+		writeError(w, r, ErrUnsupportedPatch)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		response := &Response{Status: "fail", Message: err.Error()}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	} else {
-		response := &Response{Status: "success", Message: ""}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		writeError(w, r, repo.WithKind(repo.ErrBadRequest, err))
+		return
+	}
+	expected, err := ifMatch(r, r.PathValue("isbn"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	patched, err := BookService.As(actorOf(r)).Patch(r.PathValue("isbn"), expected, func(book repo.Book) (repo.Book, error) {
+		if book.Authors == nil {
+			book.Authors = []string{}
+		}
+		doc, err := json.Marshal(book)
+		if err != nil {
+			return book, err
+		}
+		if doc, err = apply(doc, body); err != nil {
+			return book, patchError(err)
+		}
+		var changed repo.Book
+		decoder := json.NewDecoder(bytes.NewReader(doc))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&changed); err != nil {
+			return book, repo.WithKind(repo.ErrInvalid, fmt.Errorf("Patched book is not valid: %w", err))
+		}
+		return changed, nil
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setETag(w, patched)
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: patched})
+}
+
+// patchError gives the errors of the patch package their kind: an
+// unreadable patch is a bad request, a failed test a conflict with the
+// book as it is, and a path that is not in the book an invalid patch.
+func patchError(err error) error {
+	switch {
+	case errors.Is(err, patch.ErrInvalidPatch):
+		return repo.WithKind(repo.ErrBadRequest, err)
+	case errors.Is(err, patch.ErrTestFailed):
+		return repo.WithKind(repo.ErrConflict, err)
+	case errors.Is(err, patch.ErrPath):
+		return repo.WithKind(repo.ErrInvalid, err)
+	}
+	return err
+}
+
+// DeleteBook serves DELETE /api/v1/books/{isbn}, only removing the book at
+// the version of If-Match when there is one. The book can be restored until
+// it is purged.
+func DeleteBook(w http.ResponseWriter, r *http.Request) {
+	L.Info("DELETE /api/v1/books/{isbn}")
+	expected, err := ifMatch(r, r.PathValue("isbn"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := BookService.As(actorOf(r)).Remove(r.PathValue("isbn"), expected); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// partial reports whether the client opted into ?mode=partial, where the good
+// rows of a bulk request are committed and the failed ones reported back.
+func partial(r *http.Request) bool {
+	return r.URL.Query().Get("mode") == "partial"
+}
+
+// writeBulkResult answers a bulk write with one outcome per isbn. The status
+// is "success" when every book went through and "partial" when a
+// partial-mode call left some books out. A batch that was rejected or rolled
+// back is answered with a problem carrying the outcome of each book.
+func writeBulkResult(w http.ResponseWriter, r *http.Request, results []service.ItemResult, err error) {
+	if err != nil {
+		L.Error("Error: ", err)
+		problem := newProblem(r, err)
+		problem.Results = results
+		writeProblem(w, problem)
+		return
+	}
+	status := "success"
+	for _, result := range results {
+		if result.Failed() {
+			status = "partial"
+			break
+		}
 	}
+	writeJSON(w, http.StatusOK, &Response{Status: status, Message: "", Results: results})
 }
@@ -0,0 +1,21 @@
+package routers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// deprecatedSince is when the routes from before the resource paths were
+// deprecated, sent as the RFC 9745 Deprecation header.
+var deprecatedSince = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// Deprecated serves a legacy route with handler, telling clients through
+// the Deprecation and Link headers to move to successor.
+func Deprecated(successor string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecatedSince.Unix(), 10))
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		handler(w, r)
+	}
+}
@@ -0,0 +1,58 @@
+package routers
+
+import (
+	"net/http"
+	repo "server/repositories"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// noMatch is an expected version no book is ever at, for If-Match headers
+// none of whose tags can match.
+const noMatch = -1
+
+// setETag tags a response carrying book with its version. Books of schemas
+// without versions get no tag.
+func setETag(w http.ResponseWriter, book repo.Book) {
+	if book.Version > 0 {
+		w.Header().Set("ETag", `"`+strconv.Itoa(book.Version)+`"`)
+	}
+}
+
+// ifMatch reads the If-Match header of a write to the book stored under
+// number into the version the book has to be at: 0, no check, without the
+// header or with "*". If several tags are listed the one of the current
+// version is picked, and the write still fails if the book changes before
+// it is locked.
+func ifMatch(r *http.Request, number string) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	versions := []int{}
+	for _, tag := range strings.Split(header, ",") {
+		// If-Match compares tags strongly, so weak W/ tags never match.
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if version, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	switch len(versions) {
+	case 0:
+		return noMatch, nil
+	case 1:
+		return versions[0], nil
+	}
+	book, err := BookService.GetByISBN(number)
+	if err != nil {
+		return 0, err
+	}
+	if slices.Contains(versions, book.Version) {
+		return book.Version, nil
+	}
+	return noMatch, nil
+}
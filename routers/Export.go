@@ -0,0 +1,174 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"server/bookcsv"
+	repo "server/repositories"
+	"time"
+)
+
+// Export formats.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportJSON   = "json"
+)
+
+// exportFlushEvery is how many books are written between flushes, so the
+// client receives the export as it is read.
+const exportFlushEvery = 500
+
+// ExportWriteTimeout is how long a client may take to take in each book.
+// The export keeps a database cursor open while it writes, which on SQLite
+// holds the only connection, so a client that stops reading is cut off
+// rather than left to block everyone else.
+const ExportWriteTimeout = 30 * time.Second
+
+var (
+	ErrInvalidFormat = repo.NewError(repo.ErrBadRequest, "format must be csv, ndjson or json")
+	ErrExportAsOf    = repo.NewError(repo.ErrBadRequest, "as_of is not supported by the export, list the books with it instead")
+)
+
+// exportEncoder writes books in one format: start before the first book,
+// book for each one, end after the last.
+type exportEncoder struct {
+	contentType string
+	start       func() error
+	book        func(book repo.Book) error
+	end         func() error
+}
+
+func newExportEncoder(format string, w io.Writer) (exportEncoder, error) {
+	switch format {
+	case ExportCSV:
+		writer := bookcsv.NewWriter(w)
+		return exportEncoder{
+			contentType: "text/csv",
+			start:       writer.WriteHeader,
+			book:        writer.Write,
+			end:         writer.Flush,
+		}, nil
+	case ExportNDJSON:
+		encoder := json.NewEncoder(w)
+		return exportEncoder{
+			contentType: "application/x-ndjson",
+			start:       func() error { return nil },
+			book:        func(book repo.Book) error { return encoder.Encode(book) },
+			end:         func() error { return nil },
+		}, nil
+	case ExportJSON:
+		separator := "[\n"
+		return exportEncoder{
+			contentType: "application/json",
+			start:       func() error { return nil },
+			book: func(book repo.Book) error {
+				doc, err := json.Marshal(book)
+				if err == nil {
+					_, err = fmt.Fprintf(w, "%s%s", separator, doc)
+				}
+				separator = ",\n"
+				return err
+			},
+			end: func() error {
+				if separator == "[\n" {
+					_, err := io.WriteString(w, "[]\n")
+					return err
+				}
+				_, err := io.WriteString(w, "\n]\n")
+				return err
+			},
+		}, nil
+	}
+	return exportEncoder{}, ErrInvalidFormat
+}
+
+// ExportBooks serves GET /api/v1/books/export?format=csv|ndjson|json, every
+// book matching the listing filters, deleted ones too for admins with
+// ?include_deleted=true, as a download in isbn order. JSON is the default.
+// The books are written as they are read from the database and flushed as
+// they go, so the export is sent chunked and never held in memory. Once
+// the first book is out the status cannot change anymore, so a failure
+// after it cuts the download short instead, which clients see as a
+// truncated file. The catalog as it was at some time is only listed, so
+// ?as_of= is refused.
+func ExportBooks(w http.ResponseWriter, r *http.Request) {
+	L.Info("GET /api/v1/books/export")
+	if r.URL.Query().Has("as_of") {
+		writeError(w, r, ErrExportAsOf)
+		return
+	}
+	filter, err := bookFilter(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	catalog, err := reader(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = ExportJSON
+	}
+	encoder, err := newExportEncoder(format, w)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	// Writers that cannot time out, like the ones of tests, are written to
+	// without a deadline.
+	deadline := func(at time.Time) error {
+		if err := controller.SetWriteDeadline(at); !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		return nil
+	}
+	defer deadline(time.Time{})
+	started := false
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", encoder.contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="books.`+format+`"`)
+		w.WriteHeader(http.StatusOK)
+		return encoder.start()
+	}
+	written := 0
+	err = catalog.Export(filter, func(book repo.Book) error {
+		if err := deadline(time.Now().Add(ExportWriteTimeout)); err != nil {
+			return err
+		}
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := encoder.book(book); err != nil {
+			return err
+		}
+		if written++; written%exportFlushEvery == 0 {
+			return controller.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = encoder.end()
+	}
+	switch {
+	case err != nil && !started:
+		writeError(w, r, err)
+	case err != nil:
+		L.Error(fmt.Sprintf("Export cut short after %d books: ", written), err)
+	default:
+		L.Info(fmt.Sprintf("Exported %d books", written))
+	}
+}
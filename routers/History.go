@@ -0,0 +1,37 @@
+package routers
+
+import (
+	"net/http"
+	repo "server/repositories"
+)
+
+// ActorHeader names who is making a write, for the history of the books it
+// changes. The server trusts it as sent, so a proxy in front of it should
+// set it from the authenticated user.
+const ActorHeader = "X-Actor"
+
+func actorOf(r *http.Request) string {
+	if actor := r.Header.Get(ActorHeader); actor != "" {
+		return actor
+	}
+	return repo.Anonymous
+}
+
+// BookHistory serves GET /api/v1/books/{isbn}/history, one page of the
+// changes made to the book, oldest first. It takes ?limit=, ?order=asc|desc
+// and ?cursor= like the listings.
+func BookHistory(w http.ResponseWriter, r *http.Request) {
+	L.Info("GET /api/v1/books/{isbn}/history")
+	page, err := pageRequest(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	page.Sort = ""
+	history, err := BookService.History(r.PathValue("isbn"), page)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: history.Changes, NextCursor: history.NextCursor})
+}
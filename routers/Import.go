@@ -0,0 +1,196 @@
+package routers
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"server/bookcsv"
+	repo "server/repositories"
+	"server/service"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RejectsTTL is how long the rejects file of an import can be downloaded.
+const RejectsTTL = 24 * time.Hour
+
+var (
+	ErrInvalidMapping   = repo.NewError(repo.ErrBadRequest, "map must be given as header:field, such as map=Title:name")
+	ErrInvalidBatchSize = repo.NewError(repo.ErrBadRequest, "batch_size must be a positive number")
+	ErrRejectsNotFound  = repo.NewError(repo.ErrNotFound, "Rejects file not found, it may have expired")
+	// ErrUnsupportedImport is an import in a format other than CSV.
+	ErrUnsupportedImport = errors.New("Import must be text/csv")
+)
+
+// ImportResult is the outcome of an import, with where to download the
+// rows that were not imported when there are any.
+type ImportResult struct {
+	service.ImportSummary
+	Rejects string `json:"rejects,omitempty"`
+}
+
+// rejectsFile is a rejects file kept for download until expires.
+type rejectsFile struct {
+	path    string
+	expires time.Time
+}
+
+// rejectsFiles holds the rejects files of recent imports by id. Expired
+// ones are removed as new ones are added.
+var rejectsFiles = struct {
+	sync.Mutex
+	files map[string]rejectsFile
+}{files: map[string]rejectsFile{}}
+
+func keepRejects(path string) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	rejectsFiles.Lock()
+	defer rejectsFiles.Unlock()
+	now := time.Now()
+	for key, file := range rejectsFiles.files {
+		if now.After(file.expires) {
+			os.Remove(file.path)
+			delete(rejectsFiles.files, key)
+		}
+	}
+	key := hex.EncodeToString(id)
+	rejectsFiles.files[key] = rejectsFile{path: path, expires: now.Add(RejectsTTL)}
+	return key, nil
+}
+
+func findRejects(id string) (string, bool) {
+	rejectsFiles.Lock()
+	defer rejectsFiles.Unlock()
+	file, ok := rejectsFiles.files[id]
+	if !ok || time.Now().After(file.expires) {
+		return "", false
+	}
+	return file.path, true
+}
+
+// importMapping reads ?map=header:field, repeated once for every column
+// whose header is not the name of its field.
+func importMapping(r *http.Request) (bookcsv.Mapping, error) {
+	mapping := bookcsv.Mapping{}
+	for _, entry := range r.URL.Query()["map"] {
+		i := strings.LastIndex(entry, ":")
+		if i < 1 {
+			return nil, ErrInvalidMapping
+		}
+		mapping[entry[:i]] = entry[i+1:]
+	}
+	return mapping, nil
+}
+
+// ImportBooks serves POST /api/v1/books/import, adding the books of a CSV
+// file with a header row. The columns are found by header, isbn, name,
+// publish_year and authors, the authors separated by ";"; ?map= names the
+// field of any other header. The file is read as it arrives and written
+// ?batch_size= rows at a time, so a failure leaves the batches before it
+// imported. The rows that were not are collected in a rejects file, with
+// their line and why, that can be downloaded for a day from the rejects
+// URL of the result, or of the problem when the import failed part way.
+func ImportBooks(w http.ResponseWriter, r *http.Request) {
+	L.Info("POST /api/v1/books/import")
+	defer r.Body.Close()
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "text/csv" {
+		writeError(w, r, ErrUnsupportedImport)
+		return
+	}
+	mapping, err := importMapping(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	batchSize := 0
+	if params := r.URL.Query(); params.Has("batch_size") {
+		batchSize, err = strconv.Atoi(params.Get("batch_size"))
+		if err != nil || batchSize < 1 {
+			writeError(w, r, ErrInvalidBatchSize)
+			return
+		}
+	}
+	file, err := bookcsv.NewReader(r.Body, mapping)
+	if err != nil {
+		writeError(w, r, repo.WithKind(repo.ErrBadRequest, err))
+		return
+	}
+
+	rejects, err := os.CreateTemp("", "rejects-*.csv")
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	defer rejects.Close()
+	out := csv.NewWriter(rejects)
+	columns := len(file.Header())
+	out.Write(append(append([]string{}, file.Header()...), "line", "error"))
+	summary, err := BookService.As(actorOf(r)).Import(file, batchSize, func(reject service.Reject) error {
+		// Short rows and rows that could not be read at all are padded so
+		// line and error stay in their columns.
+		row := make([]string, max(columns, len(reject.Record)))
+		copy(row, reject.Record)
+		return out.Write(append(row, strconv.Itoa(reject.Line), reject.Err.Error()))
+	})
+	out.Flush()
+	if err == nil {
+		err = out.Error()
+	}
+	L.Info(fmt.Sprintf("Imported %d of %d rows", summary.Imported, summary.Rows))
+
+	result := ImportResult{ImportSummary: summary}
+	if summary.Rejected == 0 {
+		os.Remove(rejects.Name())
+	} else if id, keepErr := keepRejects(rejects.Name()); keepErr != nil {
+		os.Remove(rejects.Name())
+		if err == nil {
+			err = keepErr
+		}
+	} else {
+		result.Rejects = "/api/v1/books/import/" + id + "/rejects"
+	}
+	if err != nil {
+		// The batches before the failure are imported, so the problem
+		// still says how far it got and where their rejects are.
+		L.Error("Error: ", err)
+		problem := newProblem(r, err)
+		problem.Import = &result
+		writeProblem(w, problem)
+		return
+	}
+	status := "success"
+	if summary.Rejected > 0 {
+		status = "partial"
+	}
+	writeJSON(w, http.StatusOK, &Response{Status: status, Message: result})
+}
+
+// ImportRejects serves GET /api/v1/books/import/{id}/rejects, the rows of
+// an import that were not imported, as CSV.
+func ImportRejects(w http.ResponseWriter, r *http.Request) {
+	L.Info("GET /api/v1/books/import/{id}/rejects")
+	path, ok := findRejects(r.PathValue("id"))
+	if !ok {
+		writeError(w, r, ErrRejectsNotFound)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writeError(w, r, ErrRejectsNotFound)
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="rejects.csv"`)
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
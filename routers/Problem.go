@@ -0,0 +1,79 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	repo "server/repositories"
+	"server/service"
+)
+
+// Problem is an RFC 7807 problem details body, sent as
+// application/problem+json with every failed request.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Errors lists the invalid books of a rejected write.
+	Errors []service.ItemErrors `json:"errors,omitempty"`
+	// Results is the outcome of each book of a failed bulk write.
+	Results []service.ItemResult `json:"results,omitempty"`
+	// Import is how far a failed import got before it stopped.
+	Import *ImportResult `json:"import,omitempty"`
+}
+
+// statusOf maps the kind of err to a status code. Errors of no known kind
+// are internal.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, repo.ErrInvalid):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repo.ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, repo.ErrPrecondition):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrUnsupportedPatch), errors.Is(err, ErrUnsupportedImport):
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
+
+// newProblem describes err. The details of internal errors stay in the log.
+func newProblem(r *http.Request, err error) *Problem {
+	status := statusOf(err)
+	if status == http.StatusInternalServerError {
+		err = repo.ErrSomethingWentWrong
+	}
+	problem := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: r.URL.Path,
+	}
+	var invalid *service.ValidationError
+	if errors.As(err, &invalid) {
+		problem.Errors = invalid.Items
+	}
+	return problem
+}
+
+func writeProblem(w http.ResponseWriter, problem *Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// writeError logs err and answers with the problem it maps to.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	L.Error("Error: ", err)
+	writeProblem(w, newProblem(r, err))
+}
@@ -0,0 +1,35 @@
+package routers
+
+import "net/http"
+
+// Register serves every route of the API on mux.
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/books", Get)
+	mux.HandleFunc("POST /api/v1/books", CreateBook)
+	mux.HandleFunc("PUT /api/v1/books", Upsert)
+	mux.HandleFunc("GET /api/v1/books/{isbn}", GetBook)
+	mux.HandleFunc("PUT /api/v1/books/{isbn}", ReplaceBook)
+	mux.HandleFunc("PATCH /api/v1/books/{isbn}", PatchBook)
+	mux.HandleFunc("DELETE /api/v1/books/{isbn}", DeleteBook)
+	mux.HandleFunc("GET /api/v1/books/{isbn}/history", BookHistory)
+	mux.HandleFunc("POST /api/v1/books/{isbn}/restore", RestoreBook)
+	mux.HandleFunc("GET /api/v1/books/export", ExportBooks)
+	mux.HandleFunc("POST /api/v1/books/import", ImportBooks)
+	mux.HandleFunc("GET /api/v1/books/import/{id}/rejects", ImportRejects)
+	mux.HandleFunc("POST /api/v1/books:batchCreate", Insert)
+	mux.HandleFunc("POST /api/v1/books:batchUpdate", Update)
+	mux.HandleFunc("POST /api/v1/books:batchDelete", Delete)
+	mux.HandleFunc("GET /api/v1/books/search", Search)
+	mux.HandleFunc("GET /api/v1/suggest", Suggest)
+
+	// Routes from before the resource paths, kept for existing clients.
+	mux.HandleFunc("GET /api/v1/books/range", Deprecated("/api/v1/books", GetInRange))
+	mux.HandleFunc("POST /api/v1/books/add", Deprecated("/api/v1/books:batchCreate", Insert))
+	mux.HandleFunc("POST /api/v1/books/update", Deprecated("/api/v1/books:batchUpdate", Update))
+	mux.HandleFunc("DELETE /api/v1/books/delete", Deprecated("/api/v1/books:batchDelete", Delete))
+
+	mux.HandleFunc("GET /api/v1/authors", GetAuthors)
+	mux.HandleFunc("POST /api/v1/authors/add", InsertAuthors)
+	mux.HandleFunc("POST /api/v1/authors/update", UpdateAuthors)
+	mux.HandleFunc("DELETE /api/v1/authors/delete", DeleteAuthors)
+}
@@ -0,0 +1,93 @@
+package routers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"server/service"
+	"time"
+)
+
+// PURGE_RETENTION is how long deleted books are kept, as a Go duration such
+// as "720h", before the scheduled purge removes them for good. "0" turns
+// the purge off.
+const PURGE_RETENTION = "PURGE_RETENTION"
+
+// PURGE_INTERVAL is how often the purge runs, as a Go duration.
+const PURGE_INTERVAL = "PURGE_INTERVAL"
+
+const (
+	DefaultPurgeRetention = 30 * 24 * time.Hour
+	DefaultPurgeInterval  = time.Hour
+)
+
+// startPurge schedules the purge of deleted books as configured in the
+// environment.
+func startPurge() error {
+	retention, interval := DefaultPurgeRetention, DefaultPurgeInterval
+	for name, setting := range map[string]*time.Duration{PURGE_RETENTION: &retention, PURGE_INTERVAL: &interval} {
+		value, exist := os.LookupEnv(name)
+		if !exist {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration < 0 {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+		*setting = duration
+	}
+	if retention == 0 {
+		L.Info("Purge of deleted books is off")
+		return nil
+	}
+	if interval == 0 {
+		return fmt.Errorf("invalid %s 0", PURGE_INTERVAL)
+	}
+	L.Info(fmt.Sprintf("Purging books deleted more than %s ago every %s", retention, interval))
+	BookService.PurgeEvery(interval, retention)
+	return nil
+}
+
+// ADMIN_TOKEN is the token admins send in AdminHeader. Without it nobody is
+// an admin.
+const ADMIN_TOKEN = "ADMIN_TOKEN"
+
+// AdminHeader carries the admin token of a request.
+const AdminHeader = "X-Admin-Token"
+
+// ErrForbidden is a request for something only admins may do.
+var ErrForbidden = errors.New("include_deleted is only for admins")
+
+func isAdmin(r *http.Request) bool {
+	token := os.Getenv(ADMIN_TOKEN)
+	sent := r.Header.Get(AdminHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1
+}
+
+// reader returns the service for the reads of r. Admins can pass
+// ?include_deleted=true to see deleted books as well, anyone else asking
+// for them gets ErrForbidden.
+func reader(r *http.Request) (service.BookService, error) {
+	if r.URL.Query().Get("include_deleted") != "true" {
+		return BookService, nil
+	}
+	if !isAdmin(r) {
+		return BookService, ErrForbidden
+	}
+	return BookService.WithDeleted(), nil
+}
+
+// RestoreBook serves POST /api/v1/books/{isbn}/restore, bringing back a
+// deleted book that has not been purged yet.
+func RestoreBook(w http.ResponseWriter, r *http.Request) {
+	L.Info("POST /api/v1/books/{isbn}/restore")
+	restored, err := BookService.As(actorOf(r)).Restore(r.PathValue("isbn"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	setETag(w, restored)
+	writeJSON(w, http.StatusOK, &Response{Status: "success", Message: restored})
+}
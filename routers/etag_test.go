@@ -0,0 +1,77 @@
+package routers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/repositories"
+	"server/routers"
+	"server/service"
+	"strings"
+	"testing"
+)
+
+// atomic is the book the router tests start from.
+var atomic = repositories.Book{ISBN: "9780306406157", Name: "Atomic", Authors: []string{"Albert"}, PublishYear: 2022}
+
+// useMemory points the handlers at a fresh memory store holding atomic, at
+// version 2.
+func useMemory(t *testing.T) {
+	routers.BookService = service.BookService{
+		Repo: repositories.NewMemoryBookRepository(repositories.NewMemoryDB()),
+	}
+	if _, err := routers.BookService.Create(atomic); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := routers.BookService.Replace(atomic); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func serve(handler http.HandlerFunc, method, target, ifMatch, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestBulkUpdateIfMatch(t *testing.T) {
+	useMemory(t)
+	update := routers.Deprecated("/api/v1/books:batchUpdate", routers.Update)
+	body := `[{"isbn":"9780306406157","name":"Stale","authors":["Albert"],"publish_year":2022}]`
+
+	if w := serve(update, http.MethodPost, "/api/v1/books/update", `"1"`, body); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale If-Match, got %d: %s", w.Code, w.Body)
+	}
+	if book, _ := routers.BookService.GetByISBN("9780306406157"); book.Name != "Atomic" || book.Version != 2 {
+		t.Errorf("Expected the book to be left alone, got %v", book)
+	}
+
+	two := `[{"isbn":"9780306406157","name":"A","publish_year":2022},{"isbn":"9780804429573","name":"B","publish_year":1998}]`
+	if w := serve(update, http.MethodPost, "/api/v1/books/update", `"2"`, two); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for If-Match on several books, got %d: %s", w.Code, w.Body)
+	}
+
+	if w := serve(update, http.MethodPost, "/api/v1/books/update", `"2"`, body); w.Code != http.StatusOK {
+		t.Errorf("Expected 200 for the current version, got %d: %s", w.Code, w.Body)
+	}
+	if book, _ := routers.BookService.GetByISBN("9780306406157"); book.Name != "Stale" || book.Version != 3 {
+		t.Errorf("Expected the book to be updated, got %v", book)
+	}
+}
+
+func TestBulkDeleteIfMatch(t *testing.T) {
+	useMemory(t)
+	remove := routers.Deprecated("/api/v1/books:batchDelete", routers.Delete)
+	body := `[{"isbn":"9780306406157"}]`
+
+	if w := serve(remove, http.MethodDelete, "/api/v1/books/delete", `"1"`, body); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale If-Match, got %d: %s", w.Code, w.Body)
+	}
+	if _, err := routers.BookService.GetByISBN("9780306406157"); err != nil {
+		t.Errorf("Expected the book to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+package routers_test
+
+import (
+	"net/http"
+	"server/routers"
+	"strings"
+	"testing"
+)
+
+func TestExportBooks(t *testing.T) {
+	useMemory(t)
+
+	w := serve(routers.ExportBooks, http.MethodGet, "/api/v1/books/export?format=csv", "", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "9780306406157,Atomic,2022,Albert") {
+		t.Errorf("Expected the book exported, got %d: %s", w.Code, w.Body)
+	}
+	w = serve(routers.ExportBooks, http.MethodGet, "/api/v1/books/export?as_of=2024-03-18T00:00:00Z", "", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for as_of, got %d: %s", w.Code, w.Body)
+	}
+}
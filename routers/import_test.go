@@ -0,0 +1,58 @@
+package routers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"server/repositories"
+	"server/routers"
+	"server/service"
+	"strings"
+	"testing"
+)
+
+// failingBody serves its file, then fails as a dropped connection would.
+type failingBody struct {
+	file io.Reader
+}
+
+func (body failingBody) Read(p []byte) (int, error) {
+	n, err := body.file.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestImportKeepsRejectsOfAFailedImport(t *testing.T) {
+	routers.BookService = service.BookService{
+		Repo: repositories.NewMemoryBookRepository(repositories.NewMemoryDB()),
+	}
+	file := "isbn,name,publish_year,authors\n" +
+		"9780306406157,Atomic,2022,Albert\n" +
+		"9780804429573,,1998,\n" +
+		"9780140449136,Odyssey,2003,Homer\n"
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/books/import?batch_size=2", failingBody{strings.NewReader(file)})
+	r.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	routers.ImportBooks(w, r)
+
+	var problem routers.Problem
+	json.NewDecoder(w.Body).Decode(&problem)
+	if w.Code != http.StatusBadRequest || problem.Import == nil {
+		t.Fatalf("Expected a 400 problem with how far the import got, got %d: %v", w.Code, problem)
+	}
+	if problem.Import.Imported != 1 || problem.Import.Rejected != 1 || problem.Import.Rejects == "" {
+		t.Errorf("Expected the first batch imported and its reject kept, got %+v", *problem.Import)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, problem.Import.Rejects, nil)
+	r.SetPathValue("id", strings.Split(problem.Import.Rejects, "/")[5])
+	w = httptest.NewRecorder()
+	routers.ImportRejects(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "9780804429573,,1998,,3,name is required") {
+		t.Errorf("Expected the rejected row of the first batch, got %d: %s", w.Code, w.Body)
+	}
+}
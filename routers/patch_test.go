@@ -0,0 +1,81 @@
+package routers_test
+
+import (
+	"net/http"
+	"reflect"
+	"server/routers"
+	"strings"
+	"testing"
+)
+
+func TestPatchBook(t *testing.T) {
+	target := "/api/v1/books/9780306406157"
+	cases := []struct {
+		name, contentType, body string
+		status                  int
+		title                   string
+		authors                 []string
+		year                    int
+	}{
+		{
+			name: "merge patch", contentType: "application/merge-patch+json",
+			body:   `{"name":"Atomic Habits","authors":["Albert","Niels"]}`,
+			status: http.StatusOK, title: "Atomic Habits", authors: []string{"Albert", "Niels"}, year: 2022,
+		},
+		{
+			name: "merge patch as json", contentType: "application/json; charset=utf-8",
+			body:   `{"publish_year":2018}`,
+			status: http.StatusOK, title: "Atomic", authors: []string{"Albert"}, year: 2018,
+		},
+		{
+			name: "json patch", contentType: "application/json-patch+json",
+			body:   `[{"op":"test","path":"/name","value":"Atomic"},{"op":"replace","path":"/name","value":"Atomic Habits"},{"op":"add","path":"/authors/-","value":"Niels"}]`,
+			status: http.StatusOK, title: "Atomic Habits", authors: []string{"Albert", "Niels"}, year: 2022,
+		},
+		{
+			name: "unsupported type", contentType: "text/plain",
+			body:   `name=Atomic Habits`,
+			status: http.StatusUnsupportedMediaType, title: "Atomic", authors: []string{"Albert"}, year: 2022,
+		},
+		{
+			name: "failed test", contentType: "application/json-patch+json",
+			body:   `[{"op":"test","path":"/name","value":"Dune"},{"op":"replace","path":"/name","value":"Atomic Habits"}]`,
+			status: http.StatusConflict, title: "Atomic", authors: []string{"Albert"}, year: 2022,
+		},
+		{
+			name: "isbn merged", contentType: "application/merge-patch+json",
+			body:   `{"isbn":"9780804429573"}`,
+			status: http.StatusUnprocessableEntity, title: "Atomic", authors: []string{"Albert"}, year: 2022,
+		},
+		{
+			name: "isbn replaced", contentType: "application/json-patch+json",
+			body:   `[{"op":"replace","path":"/isbn","value":"9780804429573"}]`,
+			status: http.StatusUnprocessableEntity, title: "Atomic", authors: []string{"Albert"}, year: 2022,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			useMemory(t)
+			w := route(http.MethodPatch, target, c.contentType, `"2"`, c.body)
+			if w.Code != c.status {
+				t.Fatalf("Expected %d, got %d: %s", c.status, w.Code, w.Body)
+			}
+			if accept := w.Header().Get("Accept-Patch"); !strings.Contains(accept, "application/merge-patch+json") || !strings.Contains(accept, "application/json-patch+json") {
+				t.Errorf("Expected both patch formats in Accept-Patch, got %q", accept)
+			}
+			if c.status != http.StatusOK && w.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("Expected a problem, got %q", w.Header().Get("Content-Type"))
+			}
+			book, err := routers.BookService.GetByISBN(atomic.ISBN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if book.Name != c.title || !reflect.DeepEqual(book.Authors, c.authors) || book.PublishYear != c.year {
+				t.Errorf("Expected %s by %v in %d, got %v", c.title, c.authors, c.year, book)
+			}
+			if _, err := routers.BookService.GetByISBN("9780804429573"); err == nil {
+				t.Error("Expected no book under the patched isbn")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package routers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"server/repositories"
+	"server/routers"
+	"server/service"
+	"strings"
+	"testing"
+)
+
+// brokenStore fails every read the way a lost database would.
+type brokenStore struct {
+	repositories.BookStore
+}
+
+func (brokenStore) GetByISBN(isbn string) (repositories.Book, error) {
+	return repositories.Book{}, errors.New("connection refused")
+}
+
+// serveBook serves a request for the book at /api/v1/books/{isbn}.
+func serveBook(handler http.HandlerFunc, method, isbn, ifMatch, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/api/v1/books/"+isbn, strings.NewReader(body))
+	r.SetPathValue("isbn", isbn)
+	r.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestProblems(t *testing.T) {
+	body := `{"isbn":"9780306406157","name":"Atomic","authors":["Albert"],"publish_year":2022}`
+	cases := []struct {
+		name   string
+		broken bool
+		serve  func() *httptest.ResponseRecorder
+		status int
+		detail string
+	}{
+		{"not found", false, func() *httptest.ResponseRecorder {
+			return serveBook(routers.GetBook, http.MethodGet, "9780804429573", "", "")
+		}, http.StatusNotFound, repositories.ErrBookNotFound.Error()},
+		{"conflict", false, func() *httptest.ResponseRecorder {
+			return serve(routers.CreateBook, http.MethodPost, "/api/v1/books", "", body)
+		}, http.StatusConflict, repositories.ErrBookExists.Error()},
+		{"validation", false, func() *httptest.ResponseRecorder {
+			return serve(routers.Insert, http.MethodPost, "/api/v1/books:batchCreate", "", `[{"isbn":"9780804429573","name":"","publish_year":2022}]`)
+		}, http.StatusUnprocessableEntity, ""},
+		{"precondition", false, func() *httptest.ResponseRecorder {
+			return serveBook(routers.ReplaceBook, http.MethodPut, "9780306406157", `"1"`, body)
+		}, http.StatusPreconditionFailed, repositories.ErrVersionMismatch.Error()},
+		{"internal", true, func() *httptest.ResponseRecorder {
+			return serveBook(routers.GetBook, http.MethodGet, "9780306406157", "", "")
+		}, http.StatusInternalServerError, repositories.ErrSomethingWentWrong.Error()},
+	}
+	for _, c := range cases {
+		useMemory(t)
+		if c.broken {
+			routers.BookService = service.BookService{Repo: brokenStore{}}
+		}
+		w := c.serve()
+		if w.Code != c.status || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s: expected a %d problem, got %d %s: %s", c.name, c.status, w.Code, w.Header().Get("Content-Type"), w.Body)
+			continue
+		}
+		var problem routers.Problem
+		if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		if problem.Status != c.status || problem.Title != http.StatusText(c.status) || problem.Instance == "" {
+			t.Errorf("%s: unexpected problem %+v", c.name, problem)
+		}
+		if !strings.Contains(problem.Detail, c.detail) {
+			t.Errorf("%s: expected the detail to say %q, got %q", c.name, c.detail, problem.Detail)
+		}
+	}
+}
+
+func TestValidationProblemListsFields(t *testing.T) {
+	useMemory(t)
+	body := `[{"isbn":"9780804429573","name":"Valid","publish_year":2022},{"isbn":"0306406153","name":"","publish_year":2022}]`
+	w := serve(routers.Insert, http.MethodPost, "/api/v1/books:batchCreate", "", body)
+
+	var problem routers.Problem
+	json.NewDecoder(w.Body).Decode(&problem)
+	if w.Code != http.StatusUnprocessableEntity || len(problem.Errors) != 1 {
+		t.Fatalf("Expected a 422 with one invalid book, got %d: %+v", w.Code, problem)
+	}
+	item := problem.Errors[0]
+	if item.Index != 1 || item.ISBN != "0306406153" || len(item.Fields) != 2 || item.Fields[0].Field != "isbn" || item.Fields[1].Field != "name" {
+		t.Errorf("Expected the isbn and name of the second book, got %+v", item)
+	}
+}
@@ -0,0 +1,154 @@
+package routers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/routers"
+	"strings"
+	"testing"
+)
+
+// route sends a request through the routes of Register, the way main
+// serves them.
+func route(method, target, contentType, ifMatch, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	routers.Register(mux)
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	tests := []struct {
+		name, method, target, body, successor string
+		check                                 func(t *testing.T)
+	}{
+		{
+			name: "range", method: http.MethodGet, target: "/api/v1/books/range?from=2000",
+			successor: "/api/v1/books",
+		},
+		{
+			name: "isbn query", method: http.MethodGet, target: "/api/v1/books?isbn=9780306406157",
+			successor: "/api/v1/books/9780306406157",
+		},
+		{
+			name: "add", method: http.MethodPost, target: "/api/v1/books/add",
+			body:      `[{"isbn":"9780804429573","name":"Dune","authors":["Frank"],"publish_year":1965}]`,
+			successor: "/api/v1/books:batchCreate",
+			check: func(t *testing.T) {
+				if _, err := routers.BookService.GetByISBN("9780804429573"); err != nil {
+					t.Errorf("Expected the book to be added, got %v", err)
+				}
+			},
+		},
+		{
+			name: "update", method: http.MethodPost, target: "/api/v1/books/update",
+			body:      `[{"isbn":"9780306406157","name":"Atomic Habits","authors":["Albert"],"publish_year":2022}]`,
+			successor: "/api/v1/books:batchUpdate",
+			check: func(t *testing.T) {
+				if book, _ := routers.BookService.GetByISBN("9780306406157"); book.Name != "Atomic Habits" {
+					t.Errorf("Expected the book to be updated, got %v", book)
+				}
+			},
+		},
+		{
+			name: "delete", method: http.MethodDelete, target: "/api/v1/books/delete",
+			body:      `[{"isbn":"9780306406157"}]`,
+			successor: "/api/v1/books:batchDelete",
+			check: func(t *testing.T) {
+				if _, err := routers.BookService.GetByISBN("9780306406157"); err == nil {
+					t.Error("Expected the book to be deleted")
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useMemory(t)
+			w := route(test.method, test.target, "application/json", "", test.body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), `"status":"success"`) {
+				t.Errorf("Expected a success, got %s", w.Body)
+			}
+			if deprecation := w.Header().Get("Deprecation"); !strings.HasPrefix(deprecation, "@") {
+				t.Errorf("Expected a Deprecation date, got %q", deprecation)
+			}
+			if link, want := w.Header().Get("Link"), "<"+test.successor+`>; rel="successor-version"`; link != want {
+				t.Errorf("Expected Link %q, got %q", want, link)
+			}
+			if test.check != nil {
+				test.check(t)
+			}
+		})
+	}
+}
+
+func TestBookRoutes(t *testing.T) {
+	useMemory(t)
+	target := "/api/v1/books/9780306406157"
+
+	w := route(http.MethodGet, target, "", "", "")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("Expected the book at version 2, got %d %q: %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+	if w.Header().Get("Deprecation") != "" {
+		t.Errorf("Expected no Deprecation header on %s", target)
+	}
+
+	w = route(http.MethodPut, target, "application/json", `"2"`, `{"name":"Atomic Habits","authors":["Albert"],"publish_year":2022}`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Fatalf("Expected the book to be replaced, got %d %q: %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+
+	w = route(http.MethodPatch, target, "application/merge-patch+json", `"3"`, `{"publish_year":2018}`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"4"` {
+		t.Fatalf("Expected the book to be patched, got %d %q: %s", w.Code, w.Header().Get("ETag"), w.Body)
+	}
+	if book, _ := routers.BookService.GetByISBN(atomic.ISBN); book.Name != "Atomic Habits" || book.PublishYear != 2018 {
+		t.Errorf("Expected the replaced and patched book, got %v", book)
+	}
+
+	if w = route(http.MethodDelete, target, "", `"4"`, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204, got %d: %s", w.Code, w.Body)
+	}
+	if w = route(http.MethodGet, target, "", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for the deleted book, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestBatchRoutes(t *testing.T) {
+	useMemory(t)
+
+	w := route(http.MethodPost, "/api/v1/books:batchCreate", "application/json", "",
+		`[{"isbn":"9780804429573","name":"Dune","authors":["Frank"],"publish_year":1965}]`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"created"`) {
+		t.Fatalf("Expected the book to be created, got %d: %s", w.Code, w.Body)
+	}
+
+	w = route(http.MethodPost, "/api/v1/books:batchUpdate", "application/json", "",
+		`[{"isbn":"9780804429573","name":"Dune Messiah","authors":["Frank"],"publish_year":1969}]`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"updated"`) {
+		t.Fatalf("Expected the book to be updated, got %d: %s", w.Code, w.Body)
+	}
+	if book, _ := routers.BookService.GetByISBN("9780804429573"); book.Name != "Dune Messiah" {
+		t.Errorf("Expected the updated book, got %v", book)
+	}
+
+	w = route(http.MethodPost, "/api/v1/books:batchDelete", "application/json", "",
+		`[{"isbn":"9780804429573"},{"isbn":"9780306406157"}]`)
+	if w.Code != http.StatusOK || strings.Count(w.Body.String(), `"status":"deleted"`) != 2 {
+		t.Fatalf("Expected both books to be deleted, got %d: %s", w.Code, w.Body)
+	}
+	for _, deprecation := range []string{w.Header().Get("Deprecation"), w.Header().Get("Link")} {
+		if deprecation != "" {
+			t.Errorf("Expected no deprecation headers on the batch routes, got %q", deprecation)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package routers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/repositories"
+	"server/routers"
+	"testing"
+)
+
+func TestIncludeDeletedIsForAdmins(t *testing.T) {
+	t.Setenv(routers.ADMIN_TOKEN, "secret")
+	useMemory(t)
+	if _, err := routers.BookService.Delete([]repositories.Book{{ISBN: "9780306406157"}}); err != nil {
+		t.Fatal(err)
+	}
+	getBook := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/books/9780306406157?include_deleted=true", nil)
+		r.SetPathValue("isbn", "9780306406157")
+		if token != "" {
+			r.Header.Set(routers.AdminHeader, token)
+		}
+		w := httptest.NewRecorder()
+		routers.GetBook(w, r)
+		return w
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if w := getBook(token); w.Code != http.StatusForbidden {
+			t.Errorf("Expected 403 for token %q, got %d: %s", token, w.Code, w.Body)
+		}
+	}
+	if w := getBook("secret"); w.Code != http.StatusOK {
+		t.Errorf("Expected the deleted book for an admin, got %d: %s", w.Code, w.Body)
+	}
+
+	t.Setenv(routers.ADMIN_TOKEN, "")
+	if w := getBook(""); w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 when no admin token is configured, got %d: %s", w.Code, w.Body)
+	}
+}
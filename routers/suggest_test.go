@@ -0,0 +1,49 @@
+package routers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"server/repositories"
+	"server/routers"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	useMemory(t)
+	if _, err := routers.BookService.Create(repositories.Book{ISBN: "9780804429573", Name: "Atom Age", Authors: []string{"Albert"}, PublishYear: 1998}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := route(http.MethodGet, "/api/v1/suggest?prefix=atom", "", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		Status  string                    `json:"status"`
+		Message []repositories.Suggestion `json:"message"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	expected := []repositories.Suggestion{
+		{Text: "Atomic", Kind: repositories.SuggestTitle, Books: 1},
+		{Text: "Atom Age", Kind: repositories.SuggestTitle, Books: 1},
+	}
+	if response.Status != "success" || !reflect.DeepEqual(response.Message, expected) {
+		t.Errorf("Expected %v, got %s", expected, w.Body)
+	}
+
+	if w := route(http.MethodGet, "/api/v1/suggest?prefix=atom&limit=1", "", "", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", w.Code, w.Body)
+	} else if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || len(response.Message) != 1 {
+		t.Errorf("Expected one suggestion, got %s", w.Body)
+	}
+
+	for _, target := range []string{"/api/v1/suggest", "/api/v1/suggest?prefix=atom&limit=0"} {
+		w := route(http.MethodGet, target, "", "", "")
+		if w.Code != http.StatusBadRequest || w.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("Expected a 400 problem for %s, got %d: %s", target, w.Code, w.Body)
+		}
+	}
+}
@@ -1,6 +1,5 @@
 #!/bin/bash
 URL = $1
-git checkout master
 echo "Migrate all down to lowest version . . ."
 migrate -path db/migration -database $1 -verbose down
 echo "Migrate up to v1 . . ."
@@ -8,4 +7,4 @@ migrate -path db/migration -database $1 -verbose up 1
 echo "Inserting mock data"
 go run insertMock.go
 echo "Running server . . ."
-go run main.go
\ No newline at end of file
+DB_URL=$1 DB_SCHEMA_VERSION=1 go run main.go
\ No newline at end of file
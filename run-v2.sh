@@ -1,8 +1,4 @@
 #!/bin/bash
 URL = $1
-git checkout v2
-echo "Migrate up to v2 . . ."
-migrate -path db/migration -database $1 goto 1
-migrate -path db/migration -database $1 -verbose up 1
-echo "Running server . . ."
-go run main.go
\ No newline at end of file
+echo "Running server, it migrates the database up to v2 on startup . . ."
+DB_URL=$1 DB_SCHEMA_VERSION=2 go run main.go
\ No newline at end of file
@@ -0,0 +1,169 @@
+// Package search is a small in-process full-text index over book titles and
+// author names, for the stores that have no full-text search of their own.
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Document is one book as the index sees it.
+type Document struct {
+	ID      string
+	Title   string
+	Authors []string
+}
+
+// Hit is a matching document and how well it matched.
+type Hit struct {
+	ID    string
+	Score float64
+}
+
+// Title words count twice as much as author words.
+const (
+	titleWeight  = 2.0
+	authorWeight = 1.0
+)
+
+// BM25 parameters, the usual defaults.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type posting struct {
+	doc int
+	tf  float64
+}
+
+// Index is an inverted index from terms to the documents containing them.
+// It is immutable once built and safe for concurrent searches.
+type Index struct {
+	ids      []string
+	lengths  []float64
+	avgLen   float64
+	postings map[string][]posting
+}
+
+// Tokenize splits text into lower case words of letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func NewIndex(docs []Document) *Index {
+	index := &Index{postings: map[string][]posting{}}
+	total := 0.0
+	for i, doc := range docs {
+		tf := map[string]float64{}
+		length := 0.0
+		for _, term := range Tokenize(doc.Title) {
+			tf[term] += titleWeight
+			length += titleWeight
+		}
+		for _, author := range doc.Authors {
+			for _, term := range Tokenize(author) {
+				tf[term] += authorWeight
+				length += authorWeight
+			}
+		}
+		for term, freq := range tf {
+			index.postings[term] = append(index.postings[term], posting{doc: i, tf: freq})
+		}
+		index.ids = append(index.ids, doc.ID)
+		index.lengths = append(index.lengths, length)
+		total += length
+	}
+	if len(docs) > 0 {
+		index.avgLen = total / float64(len(docs))
+	}
+	return index
+}
+
+// Search returns up to limit documents containing any of the words of
+// query, best first. Documents matching more and rarer words rank higher;
+// ties are ordered by id.
+func (index *Index) Search(query string, limit int) []Hit {
+	scores := map[int]float64{}
+	n := float64(len(index.ids))
+	for _, term := range unique(Tokenize(query)) {
+		postings := index.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			norm := 1 - b + b*index.lengths[p.doc]/index.avgLen
+			scores[p.doc] += idf * p.tf * (k1 + 1) / (p.tf + k1*norm)
+		}
+	}
+
+	hits := []Hit{}
+	for doc, score := range scores {
+		hits = append(hits, Hit{ID: index.ids[doc], Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+func unique(terms []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			result = append(result, term)
+		}
+	}
+	return result
+}
+
+// Highlight wraps the words of text that occur in query in <mark> tags.
+// The rest of text is HTML-escaped so the result can be rendered as is.
+func Highlight(text, query string) string {
+	terms := map[string]bool{}
+	for _, term := range Tokenize(query) {
+		terms[term] = true
+	}
+	var out strings.Builder
+	start := -1
+	flush := func(end int) {
+		word := text[start:end]
+		if terms[strings.ToLower(word)] {
+			out.WriteString("<mark>" + html.EscapeString(word) + "</mark>")
+		} else {
+			out.WriteString(html.EscapeString(word))
+		}
+		start = -1
+	}
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if inWord && start < 0 {
+			start = i
+		}
+		if !inWord {
+			if start >= 0 {
+				flush(i)
+			}
+			out.WriteString(html.EscapeString(string(r)))
+		}
+	}
+	if start >= 0 {
+		flush(len(text))
+	}
+	return out.String()
+}
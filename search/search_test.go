@@ -0,0 +1,67 @@
+package search_test
+
+import (
+	"server/search"
+	"testing"
+)
+
+var docs = []search.Document{
+	{ID: "1", Title: "The Go Programming Language", Authors: []string{"Alan Donovan", "Brian Kernighan"}},
+	{ID: "2", Title: "The C Programming Language", Authors: []string{"Brian Kernighan", "Dennis Ritchie"}},
+	{ID: "3", Title: "Learning Go", Authors: []string{"Jon Bodner"}},
+	{ID: "4", Title: "Atomic Habits", Authors: []string{"James Clear"}},
+}
+
+func ids(hits []search.Hit) []string {
+	result := []string{}
+	for _, hit := range hits {
+		result = append(result, hit.ID)
+	}
+	return result
+}
+
+func TestSearchRanksByRelevance(t *testing.T) {
+	index := search.NewIndex(docs)
+
+	hits := index.Search("go kernighan", 0)
+	if got := ids(hits); len(got) != 3 || got[0] != "1" {
+		t.Errorf("Expected book 1 first out of 3 hits, got %v", got)
+	}
+
+	hits = index.Search("HABITS", 10)
+	if got := ids(hits); len(got) != 1 || got[0] != "4" {
+		t.Errorf("Expected only book 4, got %v", got)
+	}
+
+	if hits := index.Search("programming", 1); len(hits) != 1 {
+		t.Errorf("Expected the limit to apply, got %v", ids(hits))
+	}
+	if hits := index.Search("rust", 0); len(hits) != 0 {
+		t.Errorf("Expected no hits, got %v", ids(hits))
+	}
+}
+
+func TestSearchTitleOutweighsAuthor(t *testing.T) {
+	index := search.NewIndex([]search.Document{
+		{ID: "a", Title: "Clear Thinking", Authors: []string{"Shane Parrish"}},
+		{ID: "b", Title: "Atomic Habits", Authors: []string{"James Clear"}},
+	})
+	if got := ids(index.Search("clear", 0)); len(got) != 2 || got[0] != "a" {
+		t.Errorf("Expected the title match first, got %v", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	cases := map[string]string{
+		"Learning Go":      "Learning <mark>Go</mark>",
+		"Go, go & <Gone>":  "<mark>Go</mark>, <mark>go</mark> &amp; &lt;Gone&gt;",
+		"Atomic Habits":    "Atomic Habits",
+		"Café Go":          "Café <mark>Go</mark>",
+		"go-getter golang": "<mark>go</mark>-getter golang",
+	}
+	for text, expected := range cases {
+		if got := search.Highlight(text, "go"); got != expected {
+			t.Errorf("Highlight(%q) = %q, expected %q", text, got, expected)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// Entry is one text that can be suggested, such as a title or an author
+// name. Weight breaks ties between equally good matches, higher first.
+type Entry struct {
+	Text   string
+	Kind   string
+	Weight int
+}
+
+// Suggestion is an Entry that matched, with the number of typos it took.
+type Suggestion struct {
+	Entry
+	Typos int
+}
+
+// Suggester finds entries by what a user has typed so far. It is immutable
+// once built and safe for concurrent use.
+type Suggester struct {
+	entries []Entry
+	// normalized holds the entries' words joined by single spaces.
+	normalized []string
+	// vocab is every distinct word, sorted, and words maps each of them to
+	// the entries containing it.
+	vocab []string
+	runes [][]rune
+	words map[string][]int
+}
+
+func NewSuggester(entries []Entry) *Suggester {
+	s := &Suggester{entries: entries, words: map[string][]int{}}
+	for i, entry := range entries {
+		tokens := Tokenize(entry.Text)
+		s.normalized = append(s.normalized, strings.Join(tokens, " "))
+		for _, word := range unique(tokens) {
+			if _, ok := s.words[word]; !ok {
+				s.vocab = append(s.vocab, word)
+			}
+			s.words[word] = append(s.words[word], i)
+		}
+	}
+	sort.Strings(s.vocab)
+	for _, word := range s.vocab {
+		s.runes = append(s.runes, []rune(word))
+	}
+	return s
+}
+
+// maxTypos is how many edits a word of n letters may be off by. Short
+// words must match exactly or nearly every word would be a typo away.
+func maxTypos(n int) int {
+	switch {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// Suggest returns up to limit entries containing every word of input, the
+// last word of which may be unfinished. Each word may be off by a few
+// typos. Fewer typos rank first, then entries that start with the input,
+// then higher weights and shorter texts.
+func (s *Suggester) Suggest(input string, limit int) []Suggestion {
+	tokens := Tokenize(input)
+	if len(tokens) == 0 {
+		return []Suggestion{}
+	}
+
+	var typos map[int]int
+	for i, token := range tokens {
+		matched := s.match(token, i == len(tokens)-1)
+		if typos == nil {
+			typos = matched
+			continue
+		}
+		for entry, total := range typos {
+			if cost, ok := matched[entry]; ok {
+				typos[entry] = total + cost
+			} else {
+				delete(typos, entry)
+			}
+		}
+	}
+
+	query := strings.Join(tokens, " ")
+	type ranked struct {
+		Suggestion
+		starts bool
+	}
+	candidates := []ranked{}
+	for entry, cost := range typos {
+		candidates = append(candidates, ranked{
+			Suggestion: Suggestion{Entry: s.entries[entry], Typos: cost},
+			starts:     strings.HasPrefix(s.normalized[entry], query),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Typos != b.Typos {
+			return a.Typos < b.Typos
+		}
+		if a.starts != b.starts {
+			return a.starts
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		if a.Text != b.Text {
+			return a.Text < b.Text
+		}
+		return a.Kind < b.Kind
+	})
+	suggestions := []Suggestion{}
+	for _, candidate := range candidates {
+		suggestions = append(suggestions, candidate.Suggestion)
+	}
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// match returns the entries with a word close to token and the typos of
+// the closest one. With prefix set token only has to be close to the start
+// of a word.
+func (s *Suggester) match(token string, prefix bool) map[int]int {
+	matched := map[int]int{}
+	add := func(word string, cost int) {
+		for _, entry := range s.words[word] {
+			if old, ok := matched[entry]; !ok || cost < old {
+				matched[entry] = cost
+			}
+		}
+	}
+
+	k := maxTypos(len([]rune(token)))
+	if k == 0 {
+		if !prefix {
+			add(token, 0)
+			return matched
+		}
+		for i := sort.SearchStrings(s.vocab, token); i < len(s.vocab) && strings.HasPrefix(s.vocab[i], token); i++ {
+			add(s.vocab[i], 0)
+		}
+		return matched
+	}
+
+	s.scan([]rune(token), prefix, k, add)
+	return matched
+}
+
+// scan calls found with every vocab word within k typos of query, or whose
+// start is when prefix is set. Like most autocompletes it trusts the first
+// letter: only words starting with the first or, for a swap, the second
+// letter of query are considered.
+func (s *Suggester) scan(query []rune, prefix bool, k int, found func(word string, cost int)) {
+	starts := []string{string(query[:1])}
+	if len(query) > 1 && query[1] != query[0] {
+		starts = append(starts, string(query[1:2]))
+	}
+	for _, start := range starts {
+		lo := sort.SearchStrings(s.vocab, start)
+		hi := lo + sort.Search(len(s.vocab)-lo, func(x int) bool { return !strings.HasPrefix(s.vocab[lo+x], start) })
+		s.walk(lo, hi, query, prefix, k, found)
+	}
+}
+
+// walk scans vocab[lo:hi] like a trie: words sharing a start share the rows
+// of the edit distance table computed for it, and as soon as a row is over
+// k every word with that start is skipped at once.
+func (s *Suggester) walk(lo, hi int, query []rune, prefix bool, k int, found func(word string, cost int)) {
+	n := len(query)
+	// rows[d][i] is the distance between the first d letters of the current
+	// word and the first i letters of query; best[d] is the lowest
+	// rows[e][n] for e <= d, the distance to the closest prefix so far.
+	rows := [][]int{make([]int, n+1)}
+	for i := range rows[0] {
+		rows[0][i] = i
+	}
+	best := []int{n}
+	computed := 0
+	var last []rune
+
+	for w := lo; w < hi; {
+		word := s.runes[w]
+		depth := min(commonPrefix(last, word), computed)
+		last = word
+		pruned := 0
+		for d := depth + 1; d <= len(word); d++ {
+			if len(rows) <= d {
+				rows = append(rows, make([]int, n+1))
+				best = append(best, 0)
+			}
+			row, up := rows[d], rows[d-1]
+			row[0] = d
+			lowest := d
+			for i := 1; i <= n; i++ {
+				cost := 1
+				if word[d-1] == query[i-1] {
+					cost = 0
+				}
+				row[i] = min(up[i]+1, row[i-1]+1, up[i-1]+cost)
+				if d > 1 && i > 1 && word[d-1] == query[i-2] && word[d-2] == query[i-1] {
+					row[i] = min(row[i], rows[d-2][i-2]+1)
+				}
+				lowest = min(lowest, row[i])
+			}
+			best[d] = min(best[d-1], row[n])
+			if lowest > k {
+				pruned = d
+				break
+			}
+			computed = d
+		}
+
+		if pruned > 0 {
+			computed = pruned - 1
+			start := string(word[:pruned])
+			end := w + sort.Search(hi-w, func(x int) bool { return !strings.HasPrefix(s.vocab[w+x], start) })
+			// Only rows further down were over k; a start found before
+			// that is as close for every word below it.
+			if prefix && best[pruned-1] <= k {
+				for ; w < end; w++ {
+					found(s.vocab[w], best[pruned-1])
+				}
+			}
+			w = end
+			continue
+		}
+		computed = len(word)
+		cost := rows[len(word)][n]
+		if prefix {
+			cost = best[len(word)]
+		}
+		if cost <= k {
+			found(s.vocab[w], cost)
+		}
+		w++
+	}
+}
+
+func commonPrefix(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package search_test
+
+import (
+	"server/search"
+	"testing"
+)
+
+var entries = []search.Entry{
+	{Text: "The Go Programming Language", Kind: "title", Weight: 1},
+	{Text: "Learning Go", Kind: "title", Weight: 1},
+	{Text: "Godel, Escher, Bach", Kind: "title", Weight: 1},
+	{Text: "Atomic Habits", Kind: "title", Weight: 1},
+	{Text: "Brian Kernighan", Kind: "author", Weight: 2},
+	{Text: "Alan Donovan", Kind: "author", Weight: 1},
+	{Text: "James Clear", Kind: "author", Weight: 1},
+}
+
+func texts(suggestions []search.Suggestion) []string {
+	result := []string{}
+	for _, suggestion := range suggestions {
+		result = append(result, suggestion.Text)
+	}
+	return result
+}
+
+func TestSuggestPrefix(t *testing.T) {
+	s := search.NewSuggester(entries)
+
+	got := texts(s.Suggest("go", 0))
+	expected := []string{"Godel, Escher, Bach", "The Go Programming Language", "Learning Go"}
+	if len(got) != 3 || got[0] != expected[0] {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	if got := texts(s.Suggest("go prog", 0)); len(got) != 1 || got[0] != "The Go Programming Language" {
+		t.Errorf("Expected every word to match, got %v", got)
+	}
+	if got := texts(s.Suggest("a", 2)); len(got) != 2 {
+		t.Errorf("Expected the limit to apply, got %v", got)
+	}
+	if got := s.Suggest("  ", 0); len(got) != 0 {
+		t.Errorf("Expected nothing for an empty input, got %v", texts(got))
+	}
+}
+
+func TestSuggestTypos(t *testing.T) {
+	s := search.NewSuggester(entries)
+	cases := map[string]string{
+		"kernigan":  "Brian Kernighan",
+		"kerni":     "Brian Kernighan",
+		"atomci":    "Atomic Habits",
+		"hbaits":    "Atomic Habits",
+		"donavan":   "Alan Donovan",
+		"jmaes cle": "James Clear",
+	}
+	for input, expected := range cases {
+		got := s.Suggest(input, 1)
+		if len(got) != 1 || got[0].Text != expected {
+			t.Errorf("Suggest(%q) = %v, expected %q", input, texts(got), expected)
+			continue
+		}
+		if got[0].Typos == 0 && input != "kerni" {
+			t.Errorf("Suggest(%q) should count typos", input)
+		}
+	}
+	if got := s.Suggest("xyzzy", 0); len(got) != 0 {
+		t.Errorf("Expected no suggestions, got %v", texts(got))
+	}
+}
+
+func TestSuggestExactBeforeTypos(t *testing.T) {
+	s := search.NewSuggester([]search.Entry{
+		{Text: "Clean Code", Kind: "title", Weight: 9},
+		{Text: "James Clear", Kind: "author", Weight: 1},
+	})
+	got := s.Suggest("clear", 0)
+	if len(got) != 2 || got[0].Text != "James Clear" || got[0].Typos != 0 {
+		t.Errorf("Expected the exact match before the heavier near miss, got %v", texts(got))
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"server/repositories"
+	"strings"
+)
+
+var (
+	ErrAuthorName       = repositories.NewError(repositories.ErrInvalid, "Author name is required")
+	ErrAuthorNameLength = repositories.NewError(repositories.ErrInvalid, fmt.Sprintf("Author name must be at most %d characters", MaxAuthorLength))
+)
+
+type AuthorService struct {
+	Repo repositories.AuthorStore
+}
+
+func (service AuthorService) GetAll() ([]repositories.Author, error) {
+	return service.Repo.GetAll()
+}
+
+func (service AuthorService) GetByID(id int) (repositories.Author, error) {
+	return service.Repo.GetByID(id)
+}
+
+func (service AuthorService) GetByName(name string) (repositories.Author, error) {
+	return service.Repo.GetByName(name)
+}
+
+// Insert adds every author or, if any of them fails, none of them. The
+// names are all checked before anything is written.
+func (service AuthorService) Insert(authorData []repositories.Author) ([]repositories.Author, error) {
+	for _, data := range authorData {
+		if err := checkAuthorName(data.Name); err != nil {
+			return nil, err
+		}
+	}
+	created := []repositories.Author{}
+	err := service.Repo.Transaction(func(repo repositories.AuthorStore) error {
+		for _, data := range authorData {
+			author, err := repo.Insert(data)
+			if err != nil {
+				L.Error("Error: ", err)
+				return err
+			}
+			created = append(created, author)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func checkAuthorName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return ErrAuthorName
+	}
+	if len([]rune(name)) > MaxAuthorLength {
+		return ErrAuthorNameLength
+	}
+	return nil
+}
+
+// Update changes every author or, if any of them fails, none of them.
+func (service AuthorService) Update(authorData []repositories.Author) error {
+	return service.Repo.Transaction(func(repo repositories.AuthorStore) error {
+		for _, data := range authorData {
+			if err := checkAuthorName(data.Name); err != nil {
+				return err
+			}
+			if _, err := repo.GetByID(data.ID); err != nil {
+				L.Error("Error: ", err)
+				return err
+			}
+			if _, err := repo.Update(data); err != nil {
+				L.Error("Error: ", err)
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes every author or, if any of them fails, none of them.
+func (service AuthorService) Delete(authorData []repositories.Author) error {
+	return service.Repo.Transaction(func(repo repositories.AuthorStore) error {
+		for _, data := range authorData {
+			if _, err := repo.GetByID(data.ID); err != nil {
+				L.Error("Error: ", err)
+				return err
+			}
+			if _, err := repo.Delete(data.ID); err != nil {
+				L.Error("Error: ", err)
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -2,22 +2,86 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"server/isbn"
 	"server/logger"
 	"server/repositories"
+	"time"
 )
 
 type BookService struct {
-	Repo *repositories.BookRepository
+	Repo repositories.BookStore
+}
+
+// Outcomes reported per book by the bulk operations.
+const (
+	StatusCreated    = "created"
+	StatusUpdated    = "updated"
+	StatusDeleted    = "deleted"
+	StatusNotFound   = "not_found"
+	StatusDuplicate  = "duplicate"
+	StatusInvalid    = "invalid"
+	StatusError      = "error"
+	StatusRolledBack = "rolled_back"
+	StatusSkipped    = "skipped"
+	// StatusPreconditionFailed is a book sent with a version that is no
+	// longer the stored one.
+	StatusPreconditionFailed = "precondition_failed"
+)
+
+var (
+	ErrInvalidBook = repositories.NewError(repositories.ErrInvalid, "Book must have an isbn")
+	ErrISBNChanged = repositories.NewError(repositories.ErrInvalid, "The isbn of a book cannot be changed")
+	ErrBookDeleted = repositories.ErrBookDeleted
+)
+
+// ItemResult is the outcome of one book in a bulk insert, update or delete.
+type ItemResult struct {
+	ISBN   string `json:"isbn"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Fields says what is wrong with an invalid book of a partial request.
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+func (result ItemResult) Failed() bool {
+	switch result.Status {
+	case StatusCreated, StatusUpdated, StatusDeleted:
+		return false
+	}
+	return true
 }
 
 var L = logger.CreateLog()
 
+// As returns the service recording actor in the history of the books it
+// writes.
+func (service BookService) As(actor string) BookService {
+	return BookService{Repo: service.Repo.As(actor)}
+}
+
 func (service BookService) GetAllBooks() ([]repositories.Book, error) {
 	return service.Repo.GetAllBooks()
 }
 
-func (service BookService) GetByISBN(isbn string) (repositories.Book, error) {
-	return service.Repo.GetByISBN(isbn)
+// GetByISBN accepts an ISBN-10 or ISBN-13, with or without hyphens.
+func (service BookService) GetByISBN(number string) (repositories.Book, error) {
+	return service.Repo.GetByISBN(isbn.Key(number))
+}
+
+// History returns one page of the changes made to a book. Books that were
+// never written and are not stored are not found; books stored before the
+// history was kept have an empty one.
+func (service BookService) History(number string, page repositories.PageRequest) (repositories.HistoryPage, error) {
+	key := isbn.Key(number)
+	history, err := service.Repo.History(key, page)
+	if err != nil || len(history.Changes) > 0 || page.Cursor != "" {
+		return history, err
+	}
+	if _, err := service.Repo.GetByISBN(key); err != nil {
+		return repositories.HistoryPage{}, err
+	}
+	return history, nil
 }
 
 func (service BookService) GetByAuthor(author string) ([]repositories.Book, error) {
@@ -28,61 +92,333 @@ func (service BookService) GetInRange(year1, year2 int) ([]repositories.Book, er
 	return service.Repo.GetInRange(year1, year2)
 }
 
-func (service BookService) Update(bookData []repositories.Book) error {
-	var err error
-	for _, data := range bookData {
-		existingBook, errGet := service.Repo.GetByISBN(data.ISBN)
-		if errGet != nil {
-			L.Error("Error: ", errGet)
-			err = errGet
-		}
-		if existingBook == (repositories.Book{}) {
-			err = errors.New("Book not found")
-		}
+func (service BookService) List(filter repositories.BookFilter, page repositories.PageRequest) (repositories.BookPage, error) {
+	if filter.ISBN != "" {
+		filter.ISBN = isbn.Key(filter.ISBN)
+	}
+	return service.Repo.ListBooks(filter, page)
+}
 
-		_, errUpdate := service.Repo.Update(data.ISBN, data.Name, data.Author, data.PublishYear)
-		if errUpdate != nil {
-			L.Error("Error: ", errUpdate)
-			err = errUpdate
-		}
+// ListAsOf is List over the catalog as it was at the given time.
+func (service BookService) ListAsOf(at time.Time, filter repositories.BookFilter, page repositories.PageRequest) (repositories.BookPage, error) {
+	if filter.ISBN != "" {
+		filter.ISBN = isbn.Key(filter.ISBN)
 	}
-	return err
+	return service.Repo.ListBooksAsOf(at, filter, page)
+}
+
+// Export calls fn with every book matching filter, in isbn order, as it is
+// read, until fn fails.
+func (service BookService) Export(filter repositories.BookFilter, fn func(repositories.Book) error) error {
+	if filter.ISBN != "" {
+		filter.ISBN = isbn.Key(filter.ISBN)
+	}
+	return service.Repo.EachBook(filter, fn)
 }
 
-func (service BookService) Delete(bookData []repositories.Book) error {
-	var err error
-	for _, data := range bookData {
-		existingBook, errGet := service.Repo.GetByISBN(data.ISBN)
-		if errGet != nil {
-			L.Error("Error: ", errGet)
-			err = errGet
+func (service BookService) Search(query string, limit int) ([]repositories.SearchHit, error) {
+	return service.Repo.Search(query, limit)
+}
+
+func (service BookService) Suggest(prefix string, limit int) ([]repositories.Suggestion, error) {
+	return service.Repo.Suggest(prefix, limit)
+}
+
+func newResult(number, status string, err error) ItemResult {
+	result := ItemResult{ISBN: number, Status: status}
+	if err == nil {
+		return result
+	}
+	result.Error = err.Error()
+	switch {
+	case errors.Is(err, repositories.ErrNotFound):
+		result.Status = StatusNotFound
+	case errors.Is(err, repositories.ErrConflict):
+		result.Status = StatusDuplicate
+	case errors.Is(err, repositories.ErrInvalid):
+		result.Status = StatusInvalid
+	case errors.Is(err, repositories.ErrPrecondition):
+		result.Status = StatusPreconditionFailed
+	default:
+		result.Status = StatusError
+	}
+	return result
+}
+
+// operation writes one book and returns the status to report for it.
+type operation func(repo repositories.BookStore, data repositories.Book) (string, error)
+
+// bulk applies op to every book and reports one result per book.
+//
+// By default all books share one transaction: every book is checked, and if
+// any of them fails the whole batch is rolled back, so the books that had
+// succeeded are reported as rolled_back. An unexpected database error stops
+// the batch and the remaining books are reported as skipped.
+//
+// In partial mode each book is checked on its own and gets its own
+// transaction, so the good ones are committed, the invalid ones are
+// reported as invalid with what is wrong with them, and only the failed
+// ones need to be retried.
+//
+// By default a request with an invalid book is rejected with a
+// *ValidationError before anything is written.
+func (service BookService) bulk(bookData []repositories.Book, partial bool, rules rules, op operation) ([]ItemResult, error) {
+	if !partial {
+		if err := rules.validate(bookData); err != nil {
+			return nil, err
 		}
-		if existingBook == (repositories.Book{}) {
-			err = errors.New("Book not found")
+	}
+	checked := bookData
+	bookData = canonical(bookData)
+	results := make([]ItemResult, len(bookData))
+
+	if partial {
+		for i, data := range bookData {
+			if fields := rules.check(checked[i]); len(fields) > 0 {
+				results[i] = ItemResult{ISBN: data.ISBN, Status: StatusInvalid, Error: ErrInvalidItem.Error(), Fields: fields}
+				continue
+			}
+			var status string
+			err := service.Repo.Transaction(func(repo repositories.BookStore) error {
+				var err error
+				status, err = op(repo, data)
+				return err
+			})
+			if err != nil {
+				L.Error("Error: ", err)
+			}
+			results[i] = newResult(data.ISBN, status, err)
 		}
+		return results, nil
+	}
 
-		_, err2 := service.Repo.Delete(data.ISBN)
-		if err2 != nil {
-			err = err2
+	err := service.Repo.Transaction(func(repo repositories.BookStore) error {
+		var failed error
+		for i, data := range bookData {
+			status, err := op(repo, data)
+			results[i] = newResult(data.ISBN, status, err)
+			if err == nil {
+				continue
+			}
+			L.Error("Error: ", err)
+			if failed == nil {
+				failed = fmt.Errorf("%s: %w", data.ISBN, err)
+			}
+			if results[i].Status == StatusError {
+				break
+			}
 		}
+		return failed
+	})
+	if err != nil {
+		for i := range results {
+			switch {
+			case results[i].Status == "":
+				results[i] = ItemResult{ISBN: bookData[i].ISBN, Status: StatusSkipped}
+			case !results[i].Failed():
+				results[i].Status = StatusRolledBack
+			}
+		}
+	}
+	return results, err
+}
+
+// canonical returns bookData with every valid ISBN in ISBN-13 form, so the
+// results report the isbn a book is stored under. Invalid ones are left as
+// sent and rejected by the operation.
+func canonical(bookData []repositories.Book) []repositories.Book {
+	books := make([]repositories.Book, len(bookData))
+	for i, data := range bookData {
+		data.ISBN = isbn.Key(data.ISBN)
+		books[i] = data
+	}
+	return books
+}
+
+// checkVersion fails with ErrVersionMismatch unless the book stored under
+// number is at version expected, and keeps it there for the rest of the
+// transaction. An expected version of 0 asks for no check.
+func checkVersion(repo repositories.BookStore, number string, expected int) error {
+	if expected == 0 {
+		return nil
+	}
+	version, err := repo.LockVersion(number)
+	if err != nil {
+		return err
+	}
+	if version != expected {
+		return repositories.ErrVersionMismatch
+	}
+	return nil
+}
+
+func updateBook(repo repositories.BookStore, data repositories.Book) (string, error) {
+	if _, err := isbn.Normalize(data.ISBN); err != nil {
+		return "", repositories.WithKind(repositories.ErrInvalid, err)
 	}
+	if _, err := repo.GetByISBN(data.ISBN); err != nil {
+		return "", err
+	}
+	if err := checkVersion(repo, data.ISBN, data.Version); err != nil {
+		return "", err
+	}
+	_, err := repo.Update(data.ISBN, data.Name, data.Authors, data.PublishYear)
+	return StatusUpdated, err
+}
+
+func deleteBook(repo repositories.BookStore, data repositories.Book) (string, error) {
+	if _, err := repo.GetByISBN(data.ISBN); err != nil {
+		return "", err
+	}
+	if err := checkVersion(repo, data.ISBN, data.Version); err != nil {
+		return "", err
+	}
+	_, err := repo.Delete(data.ISBN)
+	return StatusDeleted, err
+}
+
+func insertBook(repo repositories.BookStore, data repositories.Book) (string, error) {
+	if data.ISBN == "" {
+		return "", ErrInvalidBook
+	}
+	if _, err := isbn.Normalize(data.ISBN); err != nil {
+		return "", repositories.WithKind(repositories.ErrInvalid, err)
+	}
+	// Deleted books are probed for too, so their tombstone is found before
+	// a failed insert can abort the transaction the other books share.
+	stored, err := repo.WithDeleted().GetByISBN(data.ISBN)
+	if err == nil && stored.DeletedAt != nil {
+		return "", ErrBookDeleted
+	}
+	if err == nil {
+		return "", repositories.ErrBookExists
+	}
+	if err != repositories.ErrBookNotFound {
+		return "", err
+	}
+	_, err = repo.Insert(data.ISBN, data.Name, data.Authors, data.PublishYear)
+	return StatusCreated, err
+}
+
+// upsertBook creates the book or overwrites it, without probing first
+// unless a version is given. A version never matches a missing book, and a
+// deleted book is a conflict like it is for insertBook.
+func upsertBook(repo repositories.BookStore, data repositories.Book) (string, error) {
+	if _, err := isbn.Normalize(data.ISBN); err != nil {
+		return "", repositories.WithKind(repositories.ErrInvalid, err)
+	}
+	if err := checkVersion(repo, data.ISBN, data.Version); err == repositories.ErrBookNotFound {
+		return "", repositories.ErrVersionMismatch
+	} else if err != nil {
+		return "", err
+	}
+	created, err := repo.Upsert(data.ISBN, data.Name, data.Authors, data.PublishYear)
+	if created {
+		return StatusCreated, err
+	}
+	return StatusUpdated, err
+}
+
+// Update changes every book or none of them.
+func (service BookService) Update(bookData []repositories.Book) ([]ItemResult, error) {
+	return service.bulk(bookData, false, writeRules, updateBook)
+}
+
+// UpdatePartial changes the books it can and reports the ones it could not.
+func (service BookService) UpdatePartial(bookData []repositories.Book) ([]ItemResult, error) {
+	return service.bulk(bookData, true, writeRules, updateBook)
+}
+
+// Delete removes every book or none of them.
+func (service BookService) Delete(bookData []repositories.Book) ([]ItemResult, error) {
+	return service.bulk(bookData, false, deleteRules, deleteBook)
+}
+
+// DeletePartial removes the books it can and reports the ones it could not.
+func (service BookService) DeletePartial(bookData []repositories.Book) ([]ItemResult, error) {
+	return service.bulk(bookData, true, deleteRules, deleteBook)
+}
+
+// Insert adds every book or none of them.
+func (service BookService) Insert(bookData []repositories.Book) ([]ItemResult, error) {
+	return service.bulk(bookData, false, writeRules, insertBook)
+}
+
+// InsertPartial adds the books it can and reports the ones it could not.
+func (service BookService) InsertPartial(bookData []repositories.Book) ([]ItemResult, error) {
+	return service.bulk(bookData, true, writeRules, insertBook)
+}
+
+// Upsert creates or overwrites every book, or none of them, and reports
+// which books were created and which updated.
+func (service BookService) Upsert(bookData []repositories.Book) ([]ItemResult, error) {
+	return service.bulk(bookData, false, writeRules, upsertBook)
+}
+
+// UpsertPartial creates or overwrites the books it can and reports the ones
+// it could not.
+func (service BookService) UpsertPartial(bookData []repositories.Book) ([]ItemResult, error) {
+	return service.bulk(bookData, true, writeRules, upsertBook)
+}
+
+// Create adds one book and returns it as stored.
+func (service BookService) Create(book repositories.Book) (repositories.Book, error) {
+	if _, err := service.Insert([]repositories.Book{book}); err != nil {
+		return repositories.Book{}, err
+	}
+	return service.GetByISBN(book.ISBN)
+}
+
+// Replace overwrites every field of an existing book and returns it as
+// stored. If book has a version the book must still be at it.
+func (service BookService) Replace(book repositories.Book) (repositories.Book, error) {
+	if _, err := service.Update([]repositories.Book{book}); err != nil {
+		return repositories.Book{}, err
+	}
+	return service.GetByISBN(book.ISBN)
+}
+
+// Remove deletes one book. Unless expected is 0 the book must still be at
+// that version.
+func (service BookService) Remove(number string, expected int) error {
+	_, err := service.Delete([]repositories.Book{{ISBN: number, Version: expected}})
 	return err
 }
 
-func (service BookService) Insert(bookData []repositories.Book) error {
-	var err error
-	for _, data := range bookData {
-		_, errGet := service.Repo.GetByISBN(data.ISBN)
-		if errGet == nil {
-			L.Error("Error: ", errGet)
-			err = errGet
+// Patch changes the book stored under number to what apply makes of it,
+// leaving alone whatever apply does not touch. The read and the write share
+// a transaction, and the result is validated like any other update. Unless
+// expected is 0 the book must still be at that version.
+func (service BookService) Patch(number string, expected int, apply func(repositories.Book) (repositories.Book, error)) (repositories.Book, error) {
+	var patched repositories.Book
+	err := service.Repo.Transaction(func(repo repositories.BookStore) error {
+		book, err := repo.GetByISBN(isbn.Key(number))
+		if err != nil {
+			return err
 		}
-
-		_, err2 := service.Repo.Insert(data.ISBN, data.Name, data.Author, data.PublishYear)
-		if err2 != nil {
-			L.Error("Error: ", err2)
-			err = err2
+		if err := checkVersion(repo, book.ISBN, expected); err != nil {
+			return err
+		}
+		changed, err := apply(book)
+		if err != nil {
+			return err
+		}
+		if isbn.Key(changed.ISBN) != book.ISBN {
+			return ErrISBNChanged
 		}
+		changed.ISBN = book.ISBN
+		if err := writeRules.validate([]repositories.Book{changed}); err != nil {
+			return err
+		}
+		if _, err := repo.Update(changed.ISBN, changed.Name, changed.Authors, changed.PublishYear); err != nil {
+			return err
+		}
+		patched, err = repo.GetByISBN(changed.ISBN)
+		return err
+	})
+	if err != nil {
+		L.Error("Error: ", err)
+		return repositories.Book{}, err
 	}
-	return err
+	return patched, nil
 }
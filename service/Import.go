@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"server/bookcsv"
+	"server/isbn"
+	"server/repositories"
+	"strings"
+)
+
+// Books are imported DefaultImportBatch at a time unless asked otherwise,
+// and never more than MaxImportBatch.
+const (
+	DefaultImportBatch = 500
+	MaxImportBatch     = 5000
+)
+
+// ImportSummary counts the rows of an import file by outcome.
+type ImportSummary struct {
+	Rows     int `json:"rows"`
+	Imported int `json:"imported"`
+	Rejected int `json:"rejected"`
+}
+
+// Reject is a row of an import file that was not imported, as it was read.
+type Reject struct {
+	Line   int
+	Record []string
+	Err    error
+}
+
+// importRow is a row waiting for its batch to be written, or to be rejected
+// in its turn when err is set.
+type importRow struct {
+	line   int
+	record []string
+	book   repositories.Book
+	err    error
+}
+
+// Import adds the books read from file, batchSize rows at a time with one
+// transaction per batch, so only one batch is ever held in memory. Rows
+// that cannot be read, are not valid or cannot be added, such as books
+// already stored, are handed to reject in the order of the file and the
+// import goes on. An error reading the file or writing a batch stops it,
+// and so does an error from reject; the batches written until then stay.
+func (service BookService) Import(file *bookcsv.Reader, batchSize int, reject func(Reject) error) (ImportSummary, error) {
+	if batchSize < 1 {
+		batchSize = DefaultImportBatch
+	}
+	batchSize = min(batchSize, MaxImportBatch)
+	summary := ImportSummary{}
+	flush := func(batch []importRow) error {
+		imported, err := service.importBatch(batch)
+		if err != nil {
+			return err
+		}
+		summary.Imported += imported
+		summary.Rejected += len(batch) - imported
+		for _, row := range batch {
+			if row.err == nil {
+				continue
+			}
+			if err := reject(Reject{Line: row.line, Record: row.record, Err: row.err}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	batch := make([]importRow, 0, batchSize)
+	for {
+		record, book, err := file.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !errors.Is(err, bookcsv.ErrRow) {
+			return summary, repositories.WithKind(repositories.ErrBadRequest, err)
+		}
+		summary.Rows++
+		if err == nil {
+			if fields := writeRules.check(book); len(fields) > 0 {
+				err = repositories.WithKind(repositories.ErrInvalid, errors.New(describe(fields)))
+			}
+		}
+		book.ISBN = isbn.Key(book.ISBN)
+		batch = append(batch, importRow{line: file.Line(), record: record, book: book, err: err})
+		if len(batch) < batchSize {
+			continue
+		}
+		if err := flush(batch); err != nil {
+			return summary, err
+		}
+		batch = batch[:0]
+	}
+	return summary, flush(batch)
+}
+
+// importBatch adds the valid books of batch in one transaction and returns
+// how many it added. Books that fail for a reason of their own get it as
+// their err; any other error rolls the whole batch back.
+func (service BookService) importBatch(batch []importRow) (int, error) {
+	imported := 0
+	pending := false
+	for _, row := range batch {
+		pending = pending || row.err == nil
+	}
+	if !pending {
+		return 0, nil
+	}
+	err := service.Repo.Transaction(func(repo repositories.BookStore) error {
+		for i, row := range batch {
+			if row.err != nil {
+				continue
+			}
+			status, err := insertBook(repo, row.book)
+			if err != nil && newResult(row.book.ISBN, status, err).Status == StatusError {
+				return err
+			}
+			if err != nil {
+				batch[i].err = err
+				continue
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		L.Error("Error: ", err)
+		return 0, err
+	}
+	return imported, nil
+}
+
+// describe joins the problems of a book into one message.
+func describe(fields []FieldError) string {
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field.Field + " " + field.Message
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"fmt"
+	"server/isbn"
+	"server/repositories"
+	"time"
+)
+
+// WithDeleted returns the service whose reads include deleted books.
+func (service BookService) WithDeleted() BookService {
+	return BookService{Repo: service.Repo.WithDeleted()}
+}
+
+// Restore brings back a deleted book and returns it as stored.
+func (service BookService) Restore(number string) (repositories.Book, error) {
+	var restored repositories.Book
+	err := service.Repo.Transaction(func(repo repositories.BookStore) error {
+		key := isbn.Key(number)
+		if err := repo.Restore(key); err != nil {
+			return err
+		}
+		var err error
+		restored, err = repo.GetByISBN(key)
+		return err
+	})
+	if err != nil {
+		L.Error("Error: ", err)
+		return repositories.Book{}, err
+	}
+	return restored, nil
+}
+
+// Purge removes for good the books deleted more than retention ago.
+func (service BookService) Purge(retention time.Duration) (int, error) {
+	return service.Repo.Purge(time.Now().Add(-retention))
+}
+
+// SystemActor is recorded in the history for the writes the server makes
+// on its own.
+const SystemActor = "system"
+
+// PurgeEvery purges the books deleted more than retention ago once every
+// interval, until the returned function is called.
+func (service BookService) PurgeEvery(interval, retention time.Duration) (stop func()) {
+	service = service.As(SystemActor)
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				purged, err := service.Purge(retention)
+				if err != nil {
+					L.Error("Error purging deleted books: ", err)
+				} else if purged > 0 {
+					L.Info(fmt.Sprintf("Purged %d deleted books", purged))
+				}
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"fmt"
+	"server/isbn"
+	"server/repositories"
+	"strings"
+	"time"
+)
+
+// MaxTextLength is the size of Book.name.
+const MaxTextLength = 255
+
+// MaxAuthorLength is the size of Author.name.
+const MaxAuthorLength = 100
+
+// MinPublishYear is the earliest publish year accepted. The latest is next
+// year, for books announced ahead of publication.
+const MinPublishYear = 1
+
+var (
+	ErrValidation = repositories.NewError(repositories.ErrInvalid, "Request contains invalid books")
+	// ErrInvalidItem is the error reported for an invalid book of a partial
+	// request, next to its field errors.
+	ErrInvalidItem = repositories.NewError(repositories.ErrInvalid, "Book is invalid")
+)
+
+// FieldError is one problem with one field of a book.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ItemErrors lists the problems of the book at Index of a request.
+type ItemErrors struct {
+	Index  int          `json:"index"`
+	ISBN   string       `json:"isbn"`
+	Fields []FieldError `json:"fields"`
+}
+
+// ValidationError rejects a whole request before anything is written. It
+// matches ErrValidation, and so ErrInvalid, with errors.Is.
+type ValidationError struct {
+	Items []ItemErrors
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %d of them", ErrValidation.Error(), len(err.Items))
+}
+
+func (err *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// rules says which checks a kind of request needs.
+type rules struct {
+	// content requires a name and a publish year and checks the authors.
+	content bool
+	// format requires a valid ISBN. Deletes do without it so books stored
+	// before ISBNs were validated can still be removed.
+	format bool
+}
+
+var (
+	writeRules  = rules{content: true, format: true}
+	deleteRules = rules{}
+)
+
+func (rules rules) check(book repositories.Book) []FieldError {
+	fields := []FieldError{}
+	add := func(field, message string) {
+		fields = append(fields, FieldError{Field: field, Message: message})
+	}
+
+	if strings.TrimSpace(book.ISBN) == "" {
+		add("isbn", "is required")
+	} else if _, err := isbn.Normalize(book.ISBN); err != nil && rules.format {
+		add("isbn", err.Error())
+	}
+	if !rules.content {
+		return fields
+	}
+
+	if strings.TrimSpace(book.Name) == "" {
+		add("name", "is required")
+	} else if len([]rune(book.Name)) > MaxTextLength {
+		add("name", fmt.Sprintf("must be at most %d characters", MaxTextLength))
+	}
+	maxYear := time.Now().Year() + 1
+	if book.PublishYear == 0 {
+		add("publish_year", "is required")
+	} else if book.PublishYear < MinPublishYear || book.PublishYear > maxYear {
+		add("publish_year", fmt.Sprintf("must be between %d and %d", MinPublishYear, maxYear))
+	}
+	for i, author := range book.Authors {
+		field := fmt.Sprintf("authors[%d]", i)
+		if strings.TrimSpace(author) == "" {
+			add(field, "must not be empty")
+		} else if len([]rune(author)) > MaxAuthorLength {
+			add(field, fmt.Sprintf("must be at most %d characters", MaxAuthorLength))
+		}
+	}
+	return fields
+}
+
+// validate checks every book of a request and returns a *ValidationError
+// listing all the invalid ones, or nil.
+func (rules rules) validate(bookData []repositories.Book) error {
+	items := []ItemErrors{}
+	for i, book := range bookData {
+		if fields := rules.check(book); len(fields) > 0 {
+			items = append(items, ItemErrors{Index: i, ISBN: book.ISBN, Fields: fields})
+		}
+	}
+	if len(items) > 0 {
+		return &ValidationError{Items: items}
+	}
+	return nil
+}
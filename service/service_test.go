@@ -2,11 +2,15 @@ package service_test
 
 import (
 	"database/sql"
+	"errors"
 	"log"
 	"reflect"
 	"regexp"
+	"server/bookcsv"
+	"server/isbn"
 	"server/repositories"
 	"server/service"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -34,16 +38,16 @@ var bookService = service.BookService{
 
 func TestGetAllBooks(t *testing.T) {
 	expected := []repositories.Book{
-		{ISBN: "19123450", Name: "Atomic", Author: "Grahahm", PublishYear: 2022},
-		{ISBN: "12235670", Name: "Skinner", Author: "Albert", PublishYear: 2001},
-		{ISBN: "12223900", Name: "Short", Author: "Victor", PublishYear: 1998},
+		{ISBN: "9780306406157", Name: "Atomic", Authors: []string{"Grahahm"}, PublishYear: 2022},
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert"}, PublishYear: 2001},
+		{ISBN: "12223900", Name: "Short", Authors: []string{"Victor"}, PublishYear: 1998},
 	}
-	rows := sqlmock.NewRows([]string{"isbn", "name", "author", "publish_year"}).
-		AddRow("19123450", "Atomic", "Grahahm", 2022).
-		AddRow("12235670", "Skinner", "Albert", 2001).
-		AddRow("12223900", "Short", "Victor", 1998)
+	rows := sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+		AddRow("9780306406157", "Atomic", 2022, "Grahahm").
+		AddRow("12235670", "Skinner", 2001, "Albert").
+		AddRow("12223900", "Short", 1998, "Victor")
 
-	mock.ExpectQuery("SELECT isbn,name,author,publish_year from Book").WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT b.isbn,b.name,b.publish_year,a.name from Book b")).WillReturnRows(rows)
 
 	book, err := bookService.GetAllBooks()
 	if err != nil {
@@ -60,11 +64,11 @@ func TestGetAllBooks(t *testing.T) {
 
 func TestGetByISBN(t *testing.T) {
 	expected := []repositories.Book{
-		{ISBN: "12235670", Name: "Skinner", Author: "Albert", PublishYear: 2001},
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert"}, PublishYear: 2001},
 	}
 
-	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "author", "publish_year"}).
-		AddRow("12235670", "Skinner", "Albert", 2001)
+	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "publish_year", "author"}).
+		AddRow("12235670", "Skinner", 2001, "Albert")
 	mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(expectedRows)
 
 	book, err := bookService.GetByISBN("12235670")
@@ -82,12 +86,13 @@ func TestGetByISBN(t *testing.T) {
 
 func TestGetByAuthor(t *testing.T) {
 	expected := []repositories.Book{
-		{ISBN: "12235670", Name: "Skinner", Author: "Albert", PublishYear: 2001},
-		{ISBN: "12289970", Name: "Stlake", Author: "Albert", PublishYear: 1997},
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert"}, PublishYear: 2001},
+		{ISBN: "12289970", Name: "Stlake", Authors: []string{"Albert", "Victor"}, PublishYear: 1997},
 	}
-	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "author", "publish_year"}).
-		AddRow("12235670", "Skinner", "Albert", 2001).
-		AddRow("12289970", "Stlake", "Albert", 1997)
+	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "publish_year", "author"}).
+		AddRow("12235670", "Skinner", 2001, "Albert").
+		AddRow("12289970", "Stlake", 1997, "Albert").
+		AddRow("12289970", "Stlake", 1997, "Victor")
 	mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(expectedRows)
 
 	book, err := bookService.GetByAuthor("Albert")
@@ -105,12 +110,12 @@ func TestGetByAuthor(t *testing.T) {
 
 func TestGetInRange(t *testing.T) {
 	expected := []repositories.Book{
-		{ISBN: "12235670", Name: "Skinner", Author: "Albert", PublishYear: 2001},
-		{ISBN: "19123450", Name: "Atomic", Author: "Grahahm", PublishYear: 2022},
+		{ISBN: "12235670", Name: "Skinner", Authors: []string{"Albert"}, PublishYear: 2001},
+		{ISBN: "9780306406157", Name: "Atomic", Authors: []string{"Grahahm"}, PublishYear: 2022},
 	}
-	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "author", "publish_year"}).
-		AddRow("12235670", "Skinner", "Albert", 2001).
-		AddRow("19123450", "Atomic", "Grahahm", 2022)
+	expectedRows := sqlmock.NewRows([]string{"isbn", "nam", "publish_year", "author"}).
+		AddRow("12235670", "Skinner", 2001, "Albert").
+		AddRow("9780306406157", "Atomic", 2022, "Grahahm")
 	mock.ExpectQuery(`SELECT (.*)`).WillReturnRows(expectedRows)
 
 	book, err := bookService.GetInRange(1999, 2023)
@@ -126,27 +131,51 @@ func TestGetInRange(t *testing.T) {
 	}
 }
 
+func expectGetByISBN(isbn string, rows *sqlmock.Rows) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT b.isbn,b.name,b.publish_year,a.name from Book b LEFT JOIN book_author ba ON ba.id_book = b.isbn LEFT JOIN Author a ON a.id = ba.id_author where b.isbn=$1`)).
+		WithArgs(isbn).
+		WillReturnRows(rows)
+}
+
+func expectLinkAuthor(isbn, author string, id int) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id,name,birth_date from Author where name=$1")).
+		WithArgs(author).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "birth_date"}).AddRow(id, author, nil))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO book_author (id_book, id_author) VALUES ($1, $2)")).
+		WithArgs(isbn, id).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
 func TestUpdate(t *testing.T) {
 
 	var bookData = []repositories.Book{
-		{ISBN: "19123450", Name: "Update 1", Author: "Author 1", PublishYear: 2022},
-		{ISBN: "19126450", Name: "Update 2", Author: "Author 2", PublishYear: 2021},
+		{ISBN: "9780306406157", Name: "Update 1", Authors: []string{"Author 1"}, PublishYear: 2022},
+		{ISBN: "9780804429573", Name: "Update 2", Authors: []string{"Author 2"}, PublishYear: 2021},
 	}
 
+	mock.ExpectBegin()
 	for _, data := range bookData {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn,name,author,publish_year from Book where "isbn"=$1`)).
+		expectGetByISBN(data.ISBN, sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+			AddRow(data.ISBN, "Atomic", 2022, "Grahahm"))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE Book SET name = $1, publish_year = $2 WHERE isbn = $3")).
+			WithArgs(data.Name, data.PublishYear, data.ISBN).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM book_author WHERE id_book = $1")).
 			WithArgs(data.ISBN).
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "name", "author", "publish_year"}).
-				AddRow(data.ISBN, "Atomic", "Grahahm", 2022))
-		mock.ExpectExec(regexp.QuoteMeta("UPDATE Book SET name = $1, publish_year = $2, author = $3 WHERE isbn = $4")).
-			WithArgs(data.Name, data.PublishYear, data.Author, data.ISBN).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectLinkAuthor(data.ISBN, data.Authors[0], 1)
 	}
 
-	err := bookService.Update(bookData)
+	mock.ExpectCommit()
+	results, err := bookService.Update(bookData)
 	if err != nil {
 		t.Errorf("Error when updating db")
 	}
+	for _, result := range results {
+		if result.Status != service.StatusUpdated {
+			t.Errorf("Expected %s to be updated, got %s", result.ISBN, result.Status)
+		}
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Unfulfilled expectations: %s", err)
@@ -156,24 +185,32 @@ func TestUpdate(t *testing.T) {
 func TestDelete(t *testing.T) {
 
 	var bookData = []repositories.Book{
-		{ISBN: "19123450", Name: "Name 1", Author: "Author 1", PublishYear: 2022},
-		{ISBN: "19126450", Name: "Name 1", Author: "Author 1", PublishYear: 2022},
+		{ISBN: "9780306406157", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022},
+		{ISBN: "9780804429573", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022},
 	}
 
+	mock.ExpectBegin()
 	for _, data := range bookData {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn,name,author,publish_year from Book where "isbn"=$1`)).
+		expectGetByISBN(data.ISBN, sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+			AddRow(data.ISBN, "Name 1", 2022, "Author 1"))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM book_author WHERE id_book = $1")).
 			WithArgs(data.ISBN).
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "name", "author", "publish_year"}).
-				AddRow(data.ISBN, "Name 1", "Author 1", 2022))
+			WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Book WHERE isbn = $1")).
 			WithArgs(data.ISBN).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 	}
 
-	err := bookService.Delete(bookData)
+	mock.ExpectCommit()
+	results, err := bookService.Delete(bookData)
 	if err != nil {
 		t.Errorf("Error when delete db")
 	}
+	for _, result := range results {
+		if result.Status != service.StatusDeleted {
+			t.Errorf("Expected %s to be deleted, got %s", result.ISBN, result.Status)
+		}
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Unfulfilled expectations: %s", err)
@@ -183,26 +220,480 @@ func TestDelete(t *testing.T) {
 func TestInsert(t *testing.T) {
 
 	var bookData = []repositories.Book{
-		{ISBN: "19123450", Name: "Name 1", Author: "Author 1", PublishYear: 2022},
-		{ISBN: "19126450", Name: "Name 2", Author: "Author 2", PublishYear: 2024},
+		{ISBN: "9780306406157", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022},
+		{ISBN: "9780804429573", Name: "Name 2", Authors: []string{"Author 2", "Author 1"}, PublishYear: 2024},
 	}
 
+	mock.ExpectBegin()
 	for _, data := range bookData {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn,name,author,publish_year from Book where "isbn"=$1`)).
-			WithArgs(data.ISBN).
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "name", "author", "publish_year"}).
-				AddRow(nil, nil, nil, nil))
-		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year, author) VALUES ($1, $2, $3, $4)")).
-			WithArgs(data.ISBN, data.Name, data.PublishYear, data.Author).
+		expectGetByISBN(data.ISBN, sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year) VALUES ($1, $2, $3)")).
+			WithArgs(data.ISBN, data.Name, data.PublishYear).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		for i, author := range data.Authors {
+			expectLinkAuthor(data.ISBN, author, i+1)
+		}
 	}
 
-	err := bookService.Insert(bookData)
+	mock.ExpectCommit()
+	results, err := bookService.Insert(bookData)
 	if err != nil {
 		t.Errorf("Error when inserting db")
 	}
+	for _, result := range results {
+		if result.Status != service.StatusCreated {
+			t.Errorf("Expected %s to be created, got %s", result.ISBN, result.Status)
+		}
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Unfulfilled expectations: %s", err)
 	}
 }
+
+func TestInsertRollsBackOnDuplicate(t *testing.T) {
+
+	var bookData = []repositories.Book{
+		{ISBN: "9780306406157", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022},
+		{ISBN: "9780804429573", Name: "Name 2", Authors: []string{"Author 2"}, PublishYear: 2024},
+	}
+
+	mock.ExpectBegin()
+	expectGetByISBN(bookData[0].ISBN, sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO Book (isbn, name, publish_year) VALUES ($1, $2, $3)")).
+		WithArgs(bookData[0].ISBN, bookData[0].Name, bookData[0].PublishYear).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectLinkAuthor(bookData[0].ISBN, "Author 1", 1)
+	expectGetByISBN(bookData[1].ISBN, sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+		AddRow(bookData[1].ISBN, "Existing", 2000, "Someone"))
+	mock.ExpectRollback()
+
+	results, err := bookService.Insert(bookData)
+	if !errors.Is(err, repositories.ErrBookExists) {
+		t.Errorf("Expected ErrBookExists, got %v", err)
+	}
+	expected := []service.ItemResult{
+		{ISBN: "9780306406157", Status: service.StatusRolledBack},
+		{ISBN: "9780804429573", Status: service.StatusDuplicate, Error: repositories.ErrBookExists.Error()},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %v, got %v", expected, results)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDeletePartial(t *testing.T) {
+
+	var bookData = []repositories.Book{
+		{ISBN: "9780306406157"},
+		{ISBN: "9780804429573"},
+	}
+
+	mock.ExpectBegin()
+	expectGetByISBN(bookData[0].ISBN, sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	expectGetByISBN(bookData[1].ISBN, sqlmock.NewRows([]string{"isbn", "name", "publish_year", "author"}).
+		AddRow(bookData[1].ISBN, "Name 2", 2022, "Author 1"))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM book_author WHERE id_book = $1")).
+		WithArgs(bookData[1].ISBN).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM Book WHERE isbn = $1")).
+		WithArgs(bookData[1].ISBN).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	results, err := bookService.DeletePartial(bookData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []service.ItemResult{
+		{ISBN: "9780306406157", Status: service.StatusNotFound, Error: repositories.ErrBookNotFound.Error()},
+		{ISBN: "9780804429573", Status: service.StatusDeleted},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %v, got %v", expected, results)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations: %s", err)
+	}
+}
+
+// stock is the book the memory service tests start from.
+var stock = repositories.Book{ISBN: "9780306406157", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022}
+
+// newMemoryService returns a service over an empty memory store.
+func newMemoryService() service.BookService {
+	return service.BookService{
+		Repo: repositories.NewMemoryBookRepository(repositories.NewMemoryDB()),
+	}
+}
+
+// newStockedService returns a service over a memory store holding stock.
+func newStockedService(t *testing.T) service.BookService {
+	memoryService := newMemoryService()
+	if _, err := memoryService.Create(stock); err != nil {
+		t.Fatal(err)
+	}
+	return memoryService
+}
+
+func TestInsertPartialInMemory(t *testing.T) {
+	memoryService := newMemoryService()
+
+	var bookData = []repositories.Book{
+		{ISBN: "9780306406157", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022},
+		{ISBN: "9780306406157", Name: "Name 1 again", Authors: []string{"Author 1"}, PublishYear: 2022},
+	}
+
+	results, err := memoryService.InsertPartial(bookData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{}
+	for _, result := range results {
+		statuses = append(statuses, result.Status)
+	}
+	expected := []string{service.StatusCreated, service.StatusDuplicate}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected statuses %v, got %v", expected, statuses)
+	}
+
+	books, _ := memoryService.GetAllBooks()
+	if len(books) != 1 || books[0].Name != "Name 1" {
+		t.Errorf("Expected only the first book to be stored, got %v", books)
+	}
+}
+
+func TestInsertNormalizesISBN(t *testing.T) {
+	memoryService := newMemoryService()
+
+	var bookData = []repositories.Book{
+		{ISBN: "0-306-40615-2", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022},
+		{ISBN: "978-0-306-40615-7", Name: "Same book", Authors: []string{"Author 1"}, PublishYear: 2022},
+	}
+
+	results, err := memoryService.InsertPartial(bookData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []service.ItemResult{
+		{ISBN: "9780306406157", Status: service.StatusCreated},
+		{ISBN: "9780306406157", Status: service.StatusDuplicate, Error: repositories.ErrBookExists.Error()},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %v, got %v", expected, results)
+	}
+
+	for _, number := range []string{"0306406152", "978-0-306-40615-7", "9780306406157"} {
+		book, err := memoryService.GetByISBN(number)
+		if err != nil || book.ISBN != "9780306406157" {
+			t.Errorf("Expected %s to find the book, got %v, %v", number, book, err)
+		}
+	}
+}
+
+func TestInsertRejectsInvalidBooks(t *testing.T) {
+	memoryService := newMemoryService()
+
+	var bookData = []repositories.Book{
+		{ISBN: "9780306406157", Name: "Valid", Authors: []string{"Author 1"}, PublishYear: 2022},
+		{ISBN: "0306406153", Name: "", Authors: []string{"Author 1", " "}, PublishYear: 99999},
+		{ISBN: "", Name: strings.Repeat("a", service.MaxTextLength+1), PublishYear: 2000},
+		{ISBN: "9780804429573", Name: "Long author", Authors: []string{strings.Repeat("a", service.MaxAuthorLength+1)}, PublishYear: 2000},
+	}
+
+	results, err := memoryService.Insert(bookData)
+	var invalid *service.ValidationError
+	if !errors.As(err, &invalid) || !errors.Is(err, service.ErrValidation) || results != nil {
+		t.Fatalf("Expected a validation error, got %v, %v", results, err)
+	}
+	fields := map[int][]string{}
+	for _, item := range invalid.Items {
+		for _, field := range item.Fields {
+			fields[item.Index] = append(fields[item.Index], field.Field)
+		}
+	}
+	expected := map[int][]string{
+		1: {"isbn", "name", "publish_year", "authors[1]"},
+		2: {"isbn", "name"},
+		3: {"authors[0]"},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected field errors %v, got %v", expected, fields)
+	}
+	if invalid.Items[0].Fields[0].Message != isbn.ErrChecksum.Error() {
+		t.Errorf("Expected the isbn error to say why, got %q", invalid.Items[0].Fields[0].Message)
+	}
+
+	if books, _ := memoryService.GetAllBooks(); len(books) != 0 {
+		t.Errorf("Expected nothing to be written, got %v", books)
+	}
+
+	if _, err := memoryService.DeletePartial([]repositories.Book{{ISBN: "133"}}); err != nil {
+		t.Errorf("Expected a delete to accept an isbn stored before validation, got %v", err)
+	}
+}
+
+func TestInsertPartialReportsInvalidBooks(t *testing.T) {
+	memoryService := newMemoryService()
+
+	results, err := memoryService.InsertPartial([]repositories.Book{
+		{ISBN: "9780306406157", Name: "Valid", Authors: []string{"Author 1"}, PublishYear: 2022},
+		{ISBN: "9780804429573", Name: "", Authors: []string{"Author 1"}, PublishYear: 2022},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []service.ItemResult{
+		{ISBN: "9780306406157", Status: service.StatusCreated},
+		{ISBN: "9780804429573", Status: service.StatusInvalid, Error: service.ErrInvalidItem.Error(), Fields: []service.FieldError{{Field: "name", Message: "is required"}}},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %v, got %v", expected, results)
+	}
+	if books, _ := memoryService.GetAllBooks(); len(books) != 1 || books[0].Name != "Valid" {
+		t.Errorf("Expected the valid book to be stored, got %v", books)
+	}
+}
+
+func TestSingleBookOperations(t *testing.T) {
+	memoryService := newMemoryService()
+
+	created, err := memoryService.Create(repositories.Book{ISBN: "0-306-40615-2", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022})
+	if err != nil || created.ISBN != "9780306406157" {
+		t.Fatalf("Expected the book to be created under its ISBN-13, got %v, %v", created, err)
+	}
+	if _, err := memoryService.Create(created); !errors.Is(err, repositories.ErrConflict) {
+		t.Errorf("Expected a conflict, got %v", err)
+	}
+
+	replaced, err := memoryService.Replace(repositories.Book{ISBN: "0306406152", Name: "Name 2", Authors: []string{"Author 2"}, PublishYear: 2023})
+	if err != nil || replaced.Name != "Name 2" || replaced.PublishYear != 2023 {
+		t.Errorf("Expected the book to be replaced, got %v, %v", replaced, err)
+	}
+
+	if err := memoryService.Remove("978-0-306-40615-7", 0); err != nil {
+		t.Errorf("Expected the book to be removed, got %v", err)
+	}
+	if err := memoryService.Remove("9780306406157", 0); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("Expected the book to be gone, got %v", err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	memoryService := newStockedService(t)
+
+	patched, err := memoryService.Patch("0306406152", 0, func(book repositories.Book) (repositories.Book, error) {
+		book.Name = "Name 2"
+		return book, nil
+	})
+	expected := repositories.Book{ISBN: "9780306406157", Name: "Name 2", Authors: []string{"Author 1"}, PublishYear: 2022, Version: 2}
+	if err != nil || !reflect.DeepEqual(patched, expected) {
+		t.Errorf("Expected only the name to change, got %v, %v", patched, err)
+	}
+
+	_, err = memoryService.Patch("9780306406157", 0, func(book repositories.Book) (repositories.Book, error) {
+		book.ISBN = "9780804429573"
+		return book, nil
+	})
+	if !errors.Is(err, service.ErrISBNChanged) {
+		t.Errorf("Expected ErrISBNChanged, got %v", err)
+	}
+	_, err = memoryService.Patch("9780306406157", 0, func(book repositories.Book) (repositories.Book, error) {
+		book.PublishYear = 99999
+		return book, nil
+	})
+	if !errors.Is(err, service.ErrValidation) {
+		t.Errorf("Expected ErrValidation, got %v", err)
+	}
+	if book, _ := memoryService.GetByISBN("9780306406157"); !reflect.DeepEqual(book, expected) {
+		t.Errorf("Expected failed patches to change nothing, got %v", book)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	memoryService := newStockedService(t)
+
+	results, err := memoryService.Upsert([]repositories.Book{
+		{ISBN: "0-306-40615-2", Name: "Name 2", Authors: []string{"Author 2"}, PublishYear: 2023},
+		{ISBN: "9780804429573", Name: "Name 3", Authors: []string{"Author 3"}, PublishYear: 2024},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []service.ItemResult{
+		{ISBN: "9780306406157", Status: service.StatusUpdated},
+		{ISBN: "9780804429573", Status: service.StatusCreated},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected results %v, got %v", expected, results)
+	}
+	if book, _ := memoryService.GetByISBN("9780306406157"); book.Name != "Name 2" {
+		t.Errorf("Expected the existing book to be overwritten, got %v", book)
+	}
+}
+
+func TestVersionChecks(t *testing.T) {
+	memoryService := newStockedService(t)
+	created, err := memoryService.GetByISBN(stock.ISBN)
+	if err != nil || created.Version != 1 {
+		t.Fatalf("Expected the book at version 1, got %v, %v", created, err)
+	}
+
+	created.Name = "Name 2"
+	replaced, err := memoryService.Replace(created)
+	if err != nil || replaced.Version != 2 {
+		t.Fatalf("Expected the book at version 2, got %v, %v", replaced, err)
+	}
+	if _, err := memoryService.Replace(created); !errors.Is(err, repositories.ErrPrecondition) {
+		t.Errorf("Expected a stale replace to fail the precondition, got %v", err)
+	}
+	_, err = memoryService.Patch("9780306406157", 1, func(book repositories.Book) (repositories.Book, error) {
+		return book, nil
+	})
+	if !errors.Is(err, repositories.ErrPrecondition) {
+		t.Errorf("Expected a stale patch to fail the precondition, got %v", err)
+	}
+	results, _ := memoryService.UpsertPartial([]repositories.Book{
+		{ISBN: "9780306406157", Name: "Name 3", Authors: []string{"Author 1"}, PublishYear: 2022, Version: 1},
+		{ISBN: "9780804429573", Name: "Name 4", Authors: []string{"Author 1"}, PublishYear: 2022, Version: 1},
+	})
+	for _, result := range results {
+		if result.Status != service.StatusPreconditionFailed {
+			t.Errorf("Expected %s to fail the precondition, got %v", result.ISBN, result)
+		}
+	}
+	if err := memoryService.Remove("9780306406157", 1); !errors.Is(err, repositories.ErrPrecondition) {
+		t.Errorf("Expected a stale delete to fail the precondition, got %v", err)
+	}
+	if err := memoryService.Remove("9780306406157", 2); err != nil {
+		t.Errorf("Expected the book to be removed, got %v", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	memoryService := newMemoryService()
+	if _, err := memoryService.As("librarian").Create(repositories.Book{ISBN: "9780306406157", Name: "Name 1", Authors: []string{"Author 1"}, PublishYear: 2022}); err != nil {
+		t.Fatal(err)
+	}
+	history, err := memoryService.History("0-306-40615-2", repositories.PageRequest{})
+	if err != nil || len(history.Changes) != 1 || history.Changes[0].Actor != "librarian" {
+		t.Errorf("Expected the insert by librarian, got %v, %v", history, err)
+	}
+	if _, err := memoryService.History("9780804429573", repositories.PageRequest{}); !errors.Is(err, repositories.ErrNotFound) {
+		t.Errorf("Expected a book that was never written to be not found, got %v", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	memoryService := newStockedService(t)
+	if err := memoryService.Remove("9780306406157", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := memoryService.Create(stock); !errors.Is(err, service.ErrBookDeleted) {
+		t.Errorf("Expected ErrBookDeleted, got %v", err)
+	}
+	results, _ := memoryService.UpsertPartial([]repositories.Book{stock})
+	expected := []service.ItemResult{{ISBN: "9780306406157", Status: service.StatusDuplicate, Error: service.ErrBookDeleted.Error()}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected the upsert to need a restore, got %v", results)
+	}
+	if _, err := memoryService.WithDeleted().GetByISBN("9780306406157"); err != nil {
+		t.Errorf("Expected the deleted book to be readable, got %v", err)
+	}
+	restored, err := memoryService.Restore("0-306-40615-2")
+	if err != nil || restored.DeletedAt != nil || restored.Name != "Name 1" {
+		t.Errorf("Expected the book to be restored, got %v, %v", restored, err)
+	}
+	if purged, err := memoryService.Purge(0); err != nil || purged != 0 {
+		t.Errorf("Expected a restored book not to be purged, got %d, %v", purged, err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	memoryService := newMemoryService()
+	memoryService.Create(repositories.Book{ISBN: "9780804429573", Name: "Stored", Authors: []string{}, PublishYear: 1998})
+	file := "Code,Title,publish_year,authors\n" +
+		"0-306-40615-2,Atomic,2022,Albert;Victor\n" +
+		"9780306406157,Atomic again,2022,\n" +
+		"9780804429573,Stored,1998,\n" +
+		"9781234567897,,2001,\n" +
+		"9780140449136,Odyssey,soon,\n" +
+		"9780140449136,Odyssey,2003,Homer\n"
+	reader, err := bookcsv.NewReader(strings.NewReader(file), bookcsv.Mapping{"Code": "isbn", "Title": "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []int{}
+	summary, err := memoryService.Import(reader, 2, func(reject service.Reject) error {
+		lines = append(lines, reject.Line)
+		return nil
+	})
+	expected := service.ImportSummary{Rows: 6, Imported: 2, Rejected: 4}
+	if err != nil || summary != expected {
+		t.Errorf("Expected %v, got %v, %v", expected, summary, err)
+	}
+	if !reflect.DeepEqual(lines, []int{3, 4, 5, 6}) {
+		t.Errorf("Expected the rows on lines 3 to 6 to be rejected, got %v", lines)
+	}
+	if book, err := memoryService.GetByISBN("9780306406157"); err != nil || book.Name != "Atomic" || len(book.Authors) != 2 {
+		t.Errorf("Expected the first row to be imported, got %v, %v", book, err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	memoryService := newMemoryService()
+	memoryService.Create(repositories.Book{ISBN: "9780306406157", Name: "Atomic", Authors: []string{"Albert"}, PublishYear: 2022})
+	memoryService.Create(repositories.Book{ISBN: "9780804429573", Name: "Short", Authors: []string{}, PublishYear: 1998})
+	exported := []string{}
+	err := memoryService.Export(repositories.BookFilter{ISBN: "0-306-40615-2"}, func(book repositories.Book) error {
+		exported = append(exported, book.ISBN)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(exported, []string{"9780306406157"}) {
+		t.Errorf("Expected the book of the ISBN-10, got %v, %v", exported, err)
+	}
+}
+
+func TestAuthorWritesAreAllOrNothing(t *testing.T) {
+	db := repositories.NewMemoryDB()
+	books := service.BookService{Repo: repositories.NewMemoryBookRepository(db)}
+	authors := service.AuthorService{Repo: repositories.NewMemoryAuthorRepository(db)}
+	if all, err := authors.GetAll(); err != nil || len(all) != 0 {
+		t.Errorf("Expected no authors and no error, got %v, %v", all, err)
+	}
+	created, err := authors.Insert([]repositories.Author{{Name: "Albert"}, {Name: "Victor"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	albert, victor := created[0], created[1]
+	if _, err := authors.Insert([]repositories.Author{{Name: "Homer"}, {Name: " "}}); !errors.Is(err, service.ErrAuthorName) {
+		t.Errorf("Expected ErrAuthorName, got %v", err)
+	}
+	if _, err := authors.GetByName("Homer"); !errors.Is(err, repositories.ErrAuthorNotFound) {
+		t.Errorf("Expected nothing to be inserted, got %v", err)
+	}
+	if _, err := books.Create(repositories.Book{ISBN: "9780306406157", Name: "Name 1", Authors: []string{"Victor"}, PublishYear: 2022}); err != nil {
+		t.Fatal(err)
+	}
+
+	err = authors.Update([]repositories.Author{{ID: albert.ID, Name: "Alberto"}, {ID: 42, Name: "Nobody"}})
+	if !errors.Is(err, repositories.ErrAuthorNotFound) {
+		t.Errorf("Expected ErrAuthorNotFound, got %v", err)
+	}
+	if author, _ := authors.GetByID(albert.ID); author.Name != "Albert" {
+		t.Errorf("Expected the rename to be rolled back, got %v", author)
+	}
+
+	if err := authors.Delete([]repositories.Author{albert, victor}); !errors.Is(err, repositories.ErrAuthorHasBooks) {
+		t.Errorf("Expected ErrAuthorHasBooks, got %v", err)
+	}
+	if _, err := authors.GetByID(albert.ID); err != nil {
+		t.Errorf("Expected Albert to be kept, got %v", err)
+	}
+}